@@ -0,0 +1,150 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// MinMaxSumCount is implemented by aggregations that track the minimum, maximum, sum and count of the recorded values,
+// like the MinMaxSumCount aggregator of earlier SDK versions. They are exported as summary lines.
+type MinMaxSumCount interface {
+	aggregation.Aggregation
+	Min() (number.Number, error)
+	Max() (number.Number, error)
+	Sum() (number.Number, error)
+	Count() (uint64, error)
+}
+
+// Points is implemented by aggregations that keep every recorded value, like the exact aggregator
+// of earlier SDK versions. The values are reduced to summary lines.
+type Points interface {
+	aggregation.Aggregation
+	Points() ([]number.Number, error)
+}
+
+// summary holds the values of a summary line. All numbers are of the given kind.
+type summary struct {
+	min, max, sum number.Number
+	count         uint64
+	kind          number.Kind
+}
+
+// summaryFor returns the summary value of aggregations that are exported as summary lines,
+// and false for all other aggregations. Aggregations are matched by the strongest interface they implement.
+// The summary value is nil if there is nothing to export.
+func (e *Exporter) summaryFor(name string, agg aggregation.Aggregation, kind number.Kind, factor float64) (metric.MetricOption, bool, error) {
+	var s *summary
+	var err error
+	switch agg := agg.(type) {
+	case MinMaxSumCount:
+		s, err = summaryFromMinMaxSumCount(agg, kind)
+	case Points:
+		s, err = summaryFromPoints(agg, kind)
+	case aggregation.Histogram:
+		s, err = summaryFromHistogram(agg, kind)
+	default:
+		return nil, false, nil
+	}
+	if err != nil || s == nil {
+		return nil, true, err
+	}
+
+	for _, n := range []*number.Number{&s.min, &s.max, &s.sum} {
+		var ok bool
+		if *n, ok = e.sanitizeValue(name, *n, s.kind, false); !ok {
+			return nil, true, nil
+		}
+	}
+	return summaryValue(s.min, s.max, s.sum, s.count, s.kind, factor), true, nil
+}
+
+func summaryFromMinMaxSumCount(agg MinMaxSumCount, kind number.Kind) (*summary, error) {
+	min, err := agg.Min()
+	if err != nil {
+		return nil, err
+	}
+	max, err := agg.Max()
+	if err != nil {
+		return nil, err
+	}
+	sum, err := agg.Sum()
+	if err != nil {
+		return nil, err
+	}
+	count, err := agg.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary{min: min, max: max, sum: sum, count: count, kind: kind}, nil
+}
+
+// summaryFromPoints reduces the points to a summary. It returns nil if there are no points.
+func summaryFromPoints(agg Points, kind number.Kind) (*summary, error) {
+	points, err := agg.Points()
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	min, max, sum := points[0], points[0], kind.Zero()
+	for _, p := range points {
+		if p.CompareNumber(kind, min) < 0 {
+			min = p
+		}
+		if p.CompareNumber(kind, max) > 0 {
+			max = p
+		}
+		sum.AddNumber(kind, p)
+	}
+
+	return &summary{min: min, max: max, sum: sum, count: uint64(len(points)), kind: kind}, nil
+}
+
+// unsupportedCounter counts the records with unsupported aggregations, by instrument name.
+type unsupportedCounter struct {
+	sync.Mutex
+	counts map[string]int64
+}
+
+// add counts a record of the instrument and reports whether it is the first one.
+func (c *unsupportedCounter) add(instrument string) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int64{}
+	}
+	c.counts[instrument]++
+	return c.counts[instrument] == 1
+}
+
+// UnsupportedAggregations returns the number of records that were not exported because their aggregation
+// is not supported, by instrument name.
+func (e *Exporter) UnsupportedAggregations() map[string]int64 {
+	e.unsupported.Lock()
+	defer e.unsupported.Unlock()
+	counts := make(map[string]int64, len(e.unsupported.counts))
+	for k, v := range e.unsupported.counts {
+		counts[k] = v
+	}
+	return counts
+}
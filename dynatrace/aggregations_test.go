@@ -0,0 +1,113 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testMinMaxSumCount struct {
+	min, max, sum number.Number
+	count         uint64
+}
+
+func (a testMinMaxSumCount) Kind() aggregation.Kind           { return "MinMaxSumCount" }
+func (a testMinMaxSumCount) Min() (number.Number, error)      { return a.min, nil }
+func (a testMinMaxSumCount) Max() (number.Number, error)      { return a.max, nil }
+func (a testMinMaxSumCount) Sum() (number.Number, error)      { return a.sum, nil }
+func (a testMinMaxSumCount) Count() (uint64, error)           { return a.count, nil }
+func (a testMinMaxSumCount) Points() ([]number.Number, error) { return nil, nil }
+
+type testPoints []number.Number
+
+func (a testPoints) Kind() aggregation.Kind           { return "Exact" }
+func (a testPoints) Points() ([]number.Number, error) { return a, nil }
+
+type testUnsupported struct{}
+
+func (testUnsupported) Kind() aggregation.Kind { return "Sketch" }
+
+func aggregationRecord(name string, kind number.Kind, agg aggregation.Aggregation) export.Record {
+	desc := metrictest.NewDescriptor(name, sdkapi.HistogramInstrumentKind, kind)
+	return export.NewRecord(&desc, attribute.EmptySet(), agg, intervalStart, intervalEnd)
+}
+
+func TestExporter_Export_SummaryAggregations(t *testing.T) {
+	lines := exportLines(t, Options{}, resource.Empty(), map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			aggregationRecord("mmsc", number.Float64Kind, testMinMaxSumCount{
+				min:   number.NewFloat64Number(0.5),
+				max:   number.NewFloat64Number(9.5),
+				sum:   number.NewFloat64Number(12),
+				count: 3,
+			}),
+			aggregationRecord("exact", number.Int64Kind, testPoints{
+				number.NewInt64Number(4),
+				number.NewInt64Number(-2),
+				number.NewInt64Number(7),
+				number.NewInt64Number(4),
+			}),
+			aggregationRecord("empty", number.Int64Kind, testPoints{}),
+		},
+	})
+
+	require.ElementsMatch(t, []string{
+		"mmsc,dt.metrics.source=opentelemetry gauge,min=0.5,max=9.5,sum=12,count=3 " + intervalEndMillis,
+		"exact,dt.metrics.source=opentelemetry gauge,min=-2,max=7,sum=13,count=4 " + intervalEndMillis,
+	}, lines)
+}
+
+func TestExporter_Export_UnsupportedAggregation(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	e, err := NewExporter(Options{
+		DryRun:                             true,
+		DryRunHandler:                      func(DryRunResult) {},
+		DisableDynatraceMetadataEnrichment: true,
+		Logger:                             zap.New(core),
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	for i := 0; i < 3; i++ {
+		reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: {
+				aggregationRecord("sketch", number.Float64Kind, testUnsupported{}),
+				aggregationRecord("other", number.Float64Kind, testUnsupported{}),
+			},
+		})
+		require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	}
+
+	reported := logs.FilterMessage("unsupported aggregation, the instrument is not exported")
+	require.Equal(t, 2, reported.Len())
+	require.Equal(t, "sketch", reported.All()[0].ContextMap()["instrument"])
+	require.Equal(t, "Sketch", reported.All()[0].ContextMap()["aggregator"])
+	require.Equal(t, map[string]int64{"sketch": 3, "other": 3}, e.UnsupportedAggregations())
+}
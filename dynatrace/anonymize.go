@@ -0,0 +1,131 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"regexp"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"go.uber.org/multierr"
+)
+
+// Anonymization replaces sensitive dimension values before they leave the process.
+type Anonymization struct {
+	// HashKeys lists the dimension keys whose values are replaced by their hex-encoded hash.
+	HashKeys []string
+	// HMACKey turns the hash into a keyed HMAC-SHA256, so values cannot be recovered by hashing guesses.
+	// Use the same key across restarts to keep the hashed values stable. If empty, plain SHA-256 is used.
+	HMACKey []byte
+	// HashLength truncates the hex-encoded hash to the given number of characters. If zero, all 64 characters are kept.
+	HashLength int
+	// Redactions replace parts of dimension values matching regular expressions.
+	// They are applied in order, before values are hashed.
+	Redactions []Redaction
+}
+
+// Redaction replaces all matches of a regular expression in dimension values.
+type Redaction struct {
+	// Pattern is the regular expression to replace.
+	Pattern string
+	// Replacement replaces each match. It can refer to submatches like regexp.ReplaceAllString.
+	Replacement string
+	// Keys limits the redaction to the given dimension keys. If empty, all dimensions are redacted.
+	Keys []string
+}
+
+type compiledRedaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+	keys        map[string]bool
+}
+
+// anonymizer applies the anonymization options to dimensions.
+type anonymizer struct {
+	hashKeys   map[string]bool
+	hmacKey    []byte
+	hashLength int
+	redactions []compiledRedaction
+}
+
+// newAnonymizer compiles the anonymization options. It returns nil if no anonymization is configured.
+func newAnonymizer(a Anonymization) (*anonymizer, error) {
+	if len(a.HashKeys) == 0 && len(a.Redactions) == 0 {
+		return nil, nil
+	}
+
+	var errs error
+	if a.HashLength < 0 || a.HashLength > hex.EncodedLen(sha256.Size) {
+		errs = multierr.Append(errs, fmt.Errorf("hash length must be between 1 and %d, got %d", hex.EncodedLen(sha256.Size), a.HashLength))
+	}
+
+	an := &anonymizer{
+		hashKeys:   toSet(a.HashKeys),
+		hmacKey:    a.HMACKey,
+		hashLength: a.HashLength,
+	}
+	for i, r := range a.Redactions {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("redaction %d: invalid pattern: %s", i, err.Error()))
+			continue
+		}
+		an.redactions = append(an.redactions, compiledRedaction{pattern: pattern, replacement: r.Replacement, keys: toSet(r.Keys)})
+	}
+
+	return an, errs
+}
+
+// anonymize returns the dimension with its value redacted and hashed as configured.
+func (a *anonymizer) anonymize(dim dimensions.Dimension) dimensions.Dimension {
+	if a == nil {
+		return dim
+	}
+
+	value := dim.Value
+	for _, r := range a.redactions {
+		if len(r.keys) == 0 || r.keys[dim.Key] {
+			value = r.pattern.ReplaceAllString(value, r.replacement)
+		}
+	}
+
+	if a.hashKeys[dim.Key] {
+		var h hash.Hash
+		if len(a.hmacKey) > 0 {
+			h = hmac.New(sha256.New, a.hmacKey)
+		} else {
+			h = sha256.New()
+		}
+		_, _ = h.Write([]byte(value))
+		value = hex.EncodeToString(h.Sum(nil))
+		if a.hashLength > 0 {
+			value = value[:a.hashLength]
+		}
+	}
+
+	return dimensions.NewDimension(dim.Key, value)
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
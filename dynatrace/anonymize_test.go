@@ -0,0 +1,97 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestAnonymizer(t *testing.T) {
+	t.Run("plain hash", func(t *testing.T) {
+		a, err := newAnonymizer(Anonymization{HashKeys: []string{"user.email"}})
+		require.NoError(t, err)
+		// echo -n jane@example.com | sha256sum
+		require.Equal(t, NewDimension("user.email", "8c87b489ce35cf2e2f39f80e282cb2e804932a56a213983eeeb428407d43b52d"),
+			a.anonymize(NewDimension("user.email", "jane@example.com")))
+		require.Equal(t, NewDimension("other", "jane@example.com"), a.anonymize(NewDimension("other", "jane@example.com")))
+	})
+
+	t.Run("HMAC is keyed and stable", func(t *testing.T) {
+		a1, err := newAnonymizer(Anonymization{HashKeys: []string{"tenant"}, HMACKey: []byte("secret"), HashLength: 16})
+		require.NoError(t, err)
+		a2, err := newAnonymizer(Anonymization{HashKeys: []string{"tenant"}, HMACKey: []byte("secret"), HashLength: 16})
+		require.NoError(t, err)
+		other, err := newAnonymizer(Anonymization{HashKeys: []string{"tenant"}, HMACKey: []byte("other"), HashLength: 16})
+		require.NoError(t, err)
+		plain, err := newAnonymizer(Anonymization{HashKeys: []string{"tenant"}, HashLength: 16})
+		require.NoError(t, err)
+
+		hashed := a1.anonymize(NewDimension("tenant", "acme")).Value
+		require.Len(t, hashed, 16)
+		require.Equal(t, hashed, a2.anonymize(NewDimension("tenant", "acme")).Value)
+		require.NotEqual(t, hashed, other.anonymize(NewDimension("tenant", "acme")).Value)
+		require.NotEqual(t, hashed, plain.anonymize(NewDimension("tenant", "acme")).Value)
+		require.NotEqual(t, hashed, a1.anonymize(NewDimension("tenant", "globex")).Value)
+	})
+
+	t.Run("redactions", func(t *testing.T) {
+		a, err := newAnonymizer(Anonymization{Redactions: []Redaction{
+			{Pattern: `[0-9]+`, Replacement: "{id}", Keys: []string{"http.target"}},
+			{Pattern: `token=[^&]*`, Replacement: "token=***"},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, "/users/{id}/orders/{id}?token=***", a.anonymize(NewDimension("http.target", "/users/12/orders/345?token=abc")).Value)
+		require.Equal(t, "v1?token=***", a.anonymize(NewDimension("url", "v1?token=abc")).Value)
+	})
+
+	t.Run("nothing configured", func(t *testing.T) {
+		a, err := newAnonymizer(Anonymization{})
+		require.NoError(t, err)
+		require.Nil(t, a)
+		require.Equal(t, NewDimension("k", "v"), a.anonymize(NewDimension("k", "v")))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewExporter(Options{DryRun: true, Anonymization: Anonymization{
+			HashKeys:   []string{"k"},
+			HashLength: 65,
+			Redactions: []Redaction{{Pattern: "("}},
+		}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "hash length must be between 1 and 64")
+		require.Contains(t, err.Error(), "redaction 0: invalid pattern")
+	})
+}
+
+func TestExporter_Export_Anonymization(t *testing.T) {
+	lines := exportLines(t, Options{
+		DefaultDimensions: []dimensions.Dimension{NewDimension("tenant", "acme")},
+		Anonymization:     Anonymization{HashKeys: []string{"user.email", "tenant"}, HMACKey: []byte("secret"), HashLength: 12},
+	}, resource.Empty(), map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {counterRecord(t, "logins", 1, attribute.String("user.email", "jane@example.com"))},
+	})
+	require.Len(t, lines, 1)
+	require.NotContains(t, lines[0], "jane@example.com")
+	require.NotContains(t, lines[0], "acme")
+	require.Regexp(t, `user\.email=[0-9a-f]{12}[, ]`, lines[0])
+	require.Regexp(t, `tenant=[0-9a-f]{12}[, ]`, lines[0])
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultClockSkewLogThreshold is the default change of the clock skew correction that is logged.
+const DefaultClockSkewLogThreshold = time.Minute
+
+// clockSkewSmoothing is the weight of a new sample in the smoothed skew estimate.
+const clockSkewSmoothing = 0.2
+
+// clockSkew estimates the offset between the local clock and the clock of the ingest API.
+type clockSkew struct {
+	mu     sync.Mutex
+	known  bool
+	skew   time.Duration
+	logged time.Duration
+}
+
+// get returns the current skew estimate, which is added to local timestamps.
+func (c *clockSkew) get() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skew
+}
+
+// observe adds a sample to the estimate and returns the new estimate.
+// It also returns whether the estimate moved by more than threshold since it was last reported.
+func (c *clockSkew) observe(sample, threshold time.Duration) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.known {
+		c.skew += time.Duration(clockSkewSmoothing * float64(sample-c.skew))
+	} else {
+		c.skew = sample
+		c.known = true
+	}
+
+	if abs(c.skew-c.logged) <= threshold {
+		return c.skew, false
+	}
+	c.logged = c.skew
+	return c.skew, true
+}
+
+// observeServerDate updates the clock skew estimate from the Date header of a response
+// to a request that was sent at sent and answered at received, both in local time.
+func (e *Exporter) observeServerDate(header http.Header, sent, received time.Time) {
+	if !e.opts.CorrectClockSkew {
+		return
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	// The Date header has a resolution of one second and is truncated, so on average it is half a second late.
+	// The server time is compared to the middle of the request.
+	server := date.Add(500 * time.Millisecond)
+	local := sent.Add(received.Sub(sent) / 2)
+
+	skew, changed := e.clockSkew.observe(server.Sub(local), e.opts.ClockSkewLogThreshold)
+	if changed {
+		e.logger.Warn("local clock differs from the ingest API clock, correcting timestamps",
+			"correction", skew,
+			"threshold", e.opts.ClockSkewLogThreshold)
+	}
+}
+
+// correctTimestamp shifts a local timestamp by the estimated clock skew.
+func (e *Exporter) correctTimestamp(ts time.Time) time.Time {
+	if !e.opts.CorrectClockSkew || ts.IsZero() {
+		return ts
+	}
+	return ts.Add(e.clockSkew.get())
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestClockSkew_observe(t *testing.T) {
+	c := clockSkew{}
+	require.Zero(t, c.get())
+
+	skew, changed := c.observe(10*time.Second, time.Minute)
+	require.Equal(t, 10*time.Second, skew)
+	require.False(t, changed)
+
+	skew, changed = c.observe(110*time.Second, time.Minute)
+	require.Equal(t, 30*time.Second, skew)
+	require.False(t, changed)
+
+	for i := 0; i < 10; i++ {
+		skew, changed = c.observe(110*time.Second, time.Minute)
+		if changed {
+			break
+		}
+	}
+	require.True(t, changed)
+	require.Greater(t, skew, time.Minute)
+	require.Equal(t, skew, c.get())
+
+	_, changed = c.observe(skew, time.Minute)
+	require.False(t, changed)
+}
+
+func TestExporter_Export_ClockSkew(t *testing.T) {
+	// The local clock is ten minutes behind the server.
+	now := intervalEnd
+	serverNow := now.Add(10 * time.Minute)
+
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		rw.Header().Set("Date", serverNow.UTC().Format(http.TimeFormat))
+		rw.Write([]byte(`{"linesOk": 1, "linesInvalid": 0, "error": null}`))
+	}))
+	defer server.Close()
+
+	run := func(t *testing.T, correct bool) *observer.ObservedLogs {
+		bodies = nil
+		core, logs := observer.New(zapcore.WarnLevel)
+		e, err := NewExporter(Options{
+			URL:                                server.URL,
+			APIToken:                           "token",
+			DisableDynatraceMetadataEnrichment: true,
+			Logger:                             zap.New(core),
+			CorrectClockSkew:                   correct,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+		e.now = func() time.Time { return now }
+
+		for i := 0; i < 2; i++ {
+			reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+				{Name: "lib"}: {counterRecord(t, "requests", 1)},
+			})
+			require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+		}
+		return logs
+	}
+
+	t.Run("corrects timestamps after the first response", func(t *testing.T) {
+		logs := run(t, true)
+		// The Date header is truncated to seconds.
+		corrected := serverNow.Truncate(time.Second).Add(500 * time.Millisecond)
+		require.Equal(t, []string{
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + strconv.FormatInt(corrected.UnixMilli(), 10),
+		}, trimLines(bodies))
+		require.Equal(t, 1, logs.FilterMessage("local clock differs from the ingest API clock, correcting timestamps").Len())
+	})
+
+	t.Run("timestamps are not corrected by default", func(t *testing.T) {
+		logs := run(t, false)
+		require.Equal(t, []string{
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+		}, trimLines(bodies))
+		require.Zero(t, logs.Len())
+	})
+}
+
+func TestExporter_observeServerDate(t *testing.T) {
+	e := &Exporter{opts: Options{CorrectClockSkew: true, ClockSkewLogThreshold: time.Minute}, logger: NewNopLogger()}
+	sent := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e.observeServerDate(http.Header{"Date": []string{"not a date"}}, sent, sent)
+	require.Zero(t, e.clockSkew.get())
+
+	// The request took two seconds, so the server answered one second into it.
+	e.observeServerDate(http.Header{"Date": []string{sent.Add(-time.Hour).Format(http.TimeFormat)}}, sent, sent.Add(2*time.Second))
+	require.Equal(t, -time.Hour-500*time.Millisecond, e.clockSkew.get())
+	require.Equal(t, sent.Add(-time.Hour-500*time.Millisecond), e.correctTimestamp(sent))
+	require.True(t, e.correctTimestamp(time.Time{}).IsZero())
+}
+
+func trimLines(bodies []string) []string {
+	lines := make([]string, 0, len(bodies))
+	for _, b := range bodies {
+		lines = append(lines, strings.TrimSpace(b))
+	}
+	return lines
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+// Default base URLs of the instance metadata endpoints.
+const (
+	DefaultAWSMetadataURL   = "http://169.254.169.254"
+	DefaultGCPMetadataURL   = "http://metadata.google.internal"
+	DefaultAzureMetadataURL = "http://169.254.169.254"
+)
+
+// DefaultCloudMetadataTimeout limits each request to an instance metadata endpoint
+// if CloudMetadataEnricher.Timeout is not set.
+const DefaultCloudMetadataTimeout = time.Second
+
+// Dimension keys set by the cloud metadata enrichment.
+const (
+	cloudProvider         = "cloud.provider"
+	cloudRegion           = "cloud.region"
+	cloudAvailabilityZone = "cloud.availability_zone"
+	cloudAccountID        = "cloud.account.id"
+	hostID                = "host.id"
+)
+
+// CloudMetadataEnricher is an Enricher that detects whether the process runs on an AWS, GCP or Azure
+// virtual machine and provides the cloud provider, region, availability zone, account and host ID as dimensions.
+// The zero value queries the default metadata endpoints.
+//
+// All endpoints are queried in parallel on the first call. If none of them answers, no dimensions are added.
+// Subsequent calls only query the provider that was detected, and return an error if it cannot be reached,
+// so that the previous dimensions are kept.
+type CloudMetadataEnricher struct {
+	// AWSBaseURL is the base URL of the AWS instance metadata service (IMDSv2).
+	AWSBaseURL string
+	// GCPBaseURL is the base URL of the GCP metadata server.
+	GCPBaseURL string
+	// AzureBaseURL is the base URL of the Azure instance metadata service.
+	AzureBaseURL string
+	// Timeout limits each request to a metadata endpoint. Defaults to DefaultCloudMetadataTimeout.
+	Timeout time.Duration
+	// Client is used for all requests. Defaults to a client without proxy, since metadata endpoints are link-local.
+	Client *http.Client
+	// Interval is the refresh interval of the enricher. If zero, the metadata is only queried once.
+	Interval time.Duration
+
+	mu       sync.Mutex
+	detected cloudMetadataProvider
+}
+
+type cloudMetadataProvider func(ctx context.Context, c *CloudMetadataEnricher) ([]dimensions.Dimension, error)
+
+// Enrich returns the metadata of the detected cloud provider.
+func (c *CloudMetadataEnricher) Enrich(ctx context.Context) ([]dimensions.Dimension, error) {
+	c.mu.Lock()
+	detected := c.detected
+	c.mu.Unlock()
+
+	if detected != nil {
+		return detected(ctx, c)
+	}
+
+	providers := []cloudMetadataProvider{awsMetadata, gcpMetadata, azureMetadata}
+	results := make([][]dimensions.Dimension, len(providers))
+	errs := make([]error, len(providers))
+
+	wg := sync.WaitGroup{}
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider cloudMetadataProvider) {
+			defer wg.Done()
+			results[i], errs[i] = provider(ctx, c)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	// AWS and Azure share the same link-local address, so the first provider in the list that answered wins.
+	for i, provider := range providers {
+		if errs[i] == nil {
+			c.mu.Lock()
+			c.detected = provider
+			c.mu.Unlock()
+			return results[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// RefreshInterval returns Interval.
+func (c *CloudMetadataEnricher) RefreshInterval() time.Duration {
+	return c.Interval
+}
+
+func (c *CloudMetadataEnricher) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return cloudMetadataClient
+}
+
+// cloudMetadataClient does not use a proxy, since metadata endpoints are link-local.
+var cloudMetadataClient = &http.Client{Transport: &http.Transport{Proxy: nil, DisableKeepAlives: true}}
+
+func (c *CloudMetadataEnricher) baseURL(configured, fallback string) string {
+	if configured == "" {
+		configured = fallback
+	}
+	return strings.TrimSuffix(configured, "/")
+}
+
+// request performs a single request to a metadata endpoint and returns the body of a 200 response.
+func (c *CloudMetadataEnricher) request(ctx context.Context, method, url string, header map[string]string) ([]byte, http.Header, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCloudMetadataTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s %s returned status %d", method, url, resp.StatusCode)
+	}
+	return body, resp.Header, nil
+}
+
+func awsMetadata(ctx context.Context, c *CloudMetadataEnricher) ([]dimensions.Dimension, error) {
+	base := c.baseURL(c.AWSBaseURL, DefaultAWSMetadataURL)
+
+	token, _, err := c.request(ctx, http.MethodPut, base+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get AWS metadata token: %s", err.Error())
+	}
+
+	body, _, err := c.request(ctx, http.MethodGet, base+"/latest/dynamic/instance-identity/document", map[string]string{
+		"X-aws-ec2-metadata-token": string(token),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get AWS instance identity: %s", err.Error())
+	}
+
+	doc := struct {
+		AccountID        string `json:"accountId"`
+		AvailabilityZone string `json:"availabilityZone"`
+		Region           string `json:"region"`
+		InstanceID       string `json:"instanceId"`
+	}{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid AWS instance identity: %s", err.Error())
+	}
+
+	return cloudDimensions("aws", doc.Region, doc.AvailabilityZone, doc.AccountID, doc.InstanceID), nil
+}
+
+func gcpMetadata(ctx context.Context, c *CloudMetadataEnricher) ([]dimensions.Dimension, error) {
+	base := c.baseURL(c.GCPBaseURL, DefaultGCPMetadataURL) + "/computeMetadata/v1"
+	header := map[string]string{"Metadata-Flavor": "Google"}
+
+	body, respHeader, err := c.request(ctx, http.MethodGet, base+"/instance/?recursive=true", header)
+	if err != nil {
+		return nil, fmt.Errorf("could not get GCP instance metadata: %s", err.Error())
+	}
+	if respHeader.Get("Metadata-Flavor") != "Google" {
+		return nil, fmt.Errorf("response is not from the GCP metadata server")
+	}
+
+	instance := struct {
+		ID   json.Number `json:"id"`
+		Zone string      `json:"zone"`
+	}{}
+	if err := json.Unmarshal(body, &instance); err != nil {
+		return nil, fmt.Errorf("invalid GCP instance metadata: %s", err.Error())
+	}
+
+	project, _, err := c.request(ctx, http.MethodGet, base+"/project/project-id", header)
+	if err != nil {
+		return nil, fmt.Errorf("could not get GCP project: %s", err.Error())
+	}
+
+	// The zone has the form projects/<project number>/zones/<region>-<zone suffix>.
+	zone := instance.Zone[strings.LastIndex(instance.Zone, "/")+1:]
+	region := ""
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+
+	return cloudDimensions("gcp", region, zone, strings.TrimSpace(string(project)), instance.ID.String()), nil
+}
+
+func azureMetadata(ctx context.Context, c *CloudMetadataEnricher) ([]dimensions.Dimension, error) {
+	base := c.baseURL(c.AzureBaseURL, DefaultAzureMetadataURL)
+
+	body, _, err := c.request(ctx, http.MethodGet, base+"/metadata/instance/compute?api-version=2021-02-01&format=json", map[string]string{
+		"Metadata": "true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get Azure instance metadata: %s", err.Error())
+	}
+
+	compute := struct {
+		Location       string `json:"location"`
+		Zone           string `json:"zone"`
+		SubscriptionID string `json:"subscriptionId"`
+		VMID           string `json:"vmId"`
+	}{}
+	if err := json.Unmarshal(body, &compute); err != nil {
+		return nil, fmt.Errorf("invalid Azure instance metadata: %s", err.Error())
+	}
+
+	return cloudDimensions("azure", compute.Location, compute.Zone, compute.SubscriptionID, compute.VMID), nil
+}
+
+// cloudDimensions returns the non-empty values as dimensions.
+func cloudDimensions(provider, region, zone, account, host string) []dimensions.Dimension {
+	dims := []dimensions.Dimension{dimensions.NewDimension(cloudProvider, provider)}
+	for _, d := range []dimensions.Dimension{
+		dimensions.NewDimension(cloudRegion, region),
+		dimensions.NewDimension(cloudAvailabilityZone, zone),
+		dimensions.NewDimension(cloudAccountID, account),
+		dimensions.NewDimension(hostID, host),
+	} {
+		if d.Value != "" {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
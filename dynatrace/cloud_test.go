@@ -0,0 +1,194 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+func newAWSMetadataServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPut && req.URL.Path == "/latest/api/token":
+			require.NotEmpty(t, req.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			fmt.Fprint(rw, "secret")
+		case req.Method == http.MethodGet && req.URL.Path == "/latest/dynamic/instance-identity/document":
+			if req.Header.Get("X-aws-ec2-metadata-token") != "secret" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(rw, `{"accountId":"123456789012","availabilityZone":"eu-west-1b","region":"eu-west-1","instanceId":"i-0abc"}`)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newGCPMetadataServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Metadata-Flavor") != "Google" {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		rw.Header().Set("Metadata-Flavor", "Google")
+		switch req.URL.Path {
+		case "/computeMetadata/v1/instance/":
+			fmt.Fprint(rw, `{"id":4520031799277581759,"zone":"projects/1234/zones/us-central1-a","name":"vm"}`)
+		case "/computeMetadata/v1/project/project-id":
+			fmt.Fprint(rw, "my-project")
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newAzureMetadataServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Metadata") != "true" || req.URL.Path != "/metadata/instance/compute" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(rw, `{"location":"westeurope","zone":"","subscriptionId":"sub-1","vmId":"vm-1"}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// unreachableURL returns the URL of a server that is already closed.
+func unreachableURL() string {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+	return s.URL
+}
+
+func TestCloudMetadataEnricher(t *testing.T) {
+	t.Run("AWS", func(t *testing.T) {
+		c := &CloudMetadataEnricher{
+			AWSBaseURL:   newAWSMetadataServer(t).URL,
+			GCPBaseURL:   unreachableURL(),
+			AzureBaseURL: unreachableURL(),
+		}
+		dims, err := c.Enrich(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension("cloud.provider", "aws"),
+			NewDimension("cloud.region", "eu-west-1"),
+			NewDimension("cloud.availability_zone", "eu-west-1b"),
+			NewDimension("cloud.account.id", "123456789012"),
+			NewDimension("host.id", "i-0abc"),
+		}, dims)
+	})
+
+	t.Run("GCP", func(t *testing.T) {
+		c := &CloudMetadataEnricher{
+			AWSBaseURL:   unreachableURL(),
+			GCPBaseURL:   newGCPMetadataServer(t).URL,
+			AzureBaseURL: unreachableURL(),
+		}
+		dims, err := c.Enrich(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension("cloud.provider", "gcp"),
+			NewDimension("cloud.region", "us-central1"),
+			NewDimension("cloud.availability_zone", "us-central1-a"),
+			NewDimension("cloud.account.id", "my-project"),
+			NewDimension("host.id", "4520031799277581759"),
+		}, dims)
+	})
+
+	t.Run("Azure", func(t *testing.T) {
+		c := &CloudMetadataEnricher{
+			AWSBaseURL:   unreachableURL(),
+			GCPBaseURL:   unreachableURL(),
+			AzureBaseURL: newAzureMetadataServer(t).URL,
+		}
+		dims, err := c.Enrich(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension("cloud.provider", "azure"),
+			NewDimension("cloud.region", "westeurope"),
+			NewDimension("cloud.account.id", "sub-1"),
+			NewDimension("host.id", "vm-1"),
+		}, dims)
+	})
+
+	t.Run("no cloud", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-req.Context().Done():
+			}
+		}))
+		defer slow.Close()
+
+		c := &CloudMetadataEnricher{
+			AWSBaseURL:   slow.URL,
+			GCPBaseURL:   unreachableURL(),
+			AzureBaseURL: unreachableURL(),
+			Timeout:      10 * time.Millisecond,
+		}
+		start := time.Now()
+		dims, err := c.Enrich(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, dims)
+		require.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	})
+
+	t.Run("refresh only queries the detected provider", func(t *testing.T) {
+		aws := newAWSMetadataServer(t)
+		c := &CloudMetadataEnricher{
+			AWSBaseURL:   aws.URL,
+			GCPBaseURL:   newGCPMetadataServer(t).URL,
+			AzureBaseURL: unreachableURL(),
+			Interval:     time.Minute,
+		}
+		dims, err := c.Enrich(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, NewDimension("cloud.provider", "aws"), dims[0])
+		require.Equal(t, time.Minute, c.RefreshInterval())
+
+		aws.Close()
+		_, err = c.Enrich(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestExporter_CloudMetadataEnricher(t *testing.T) {
+	e, err := NewExporter(Options{
+		DryRun:                             true,
+		DisableDynatraceMetadataEnrichment: true,
+		Enrichers: []Enricher{&CloudMetadataEnricher{
+			AWSBaseURL:   unreachableURL(),
+			GCPBaseURL:   unreachableURL(),
+			AzureBaseURL: newAzureMetadataServer(t).URL,
+		}},
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.Contains(t, staticDimensionsOf(e), "cloud.provider=azure;")
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/lineprotocol"
+)
+
+// DryRunResult summarizes the lines produced by a single Export call in dry-run mode.
+type DryRunResult struct {
+	// Valid contains the lines that would have been sent.
+	Valid []string
+	// Invalid contains the lines that would have been rejected by the ingest API.
+	Invalid []InvalidLine
+}
+
+// InvalidLine is a serialized line that does not conform to the Dynatrace line protocol.
+type InvalidLine struct {
+	Line string
+	// Column is the 1-based position in Line at which the violation was detected.
+	Column int
+	Reason string
+}
+
+// newInvalidLine describes the validation error of a line. Errors other than syntax errors have no column.
+func newInvalidLine(line string, err error) InvalidLine {
+	var syntaxErr *lineprotocol.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return InvalidLine{Line: line, Column: syntaxErr.Column, Reason: syntaxErr.Msg}
+	}
+	return InvalidLine{Line: line, Reason: err.Error()}
+}
+
+// dryRun validates lines and hands the result to the configured handler, or logs it if there is none.
+func (e *Exporter) dryRun(lines []string) {
+	result := DryRunResult{Valid: []string{}, Invalid: []InvalidLine{}}
+	for _, line := range lines {
+		var err error
+		if strings.HasPrefix(line, "#") {
+			_, err = lineprotocol.ParseMetadata(line)
+		} else {
+			_, err = lineprotocol.ParseLine(line)
+		}
+		if err != nil {
+			result.Invalid = append(result.Invalid, newInvalidLine(line, err))
+		} else {
+			result.Valid = append(result.Valid, line)
+		}
+	}
+
+	if e.opts.DryRunHandler != nil {
+		e.opts.DryRunHandler(result)
+		return
+	}
+
+	for _, line := range result.Valid {
+		e.logger.Info("dry run: valid line", "line", line)
+	}
+	for _, invalid := range result.Invalid {
+		e.logger.Warn("dry run: invalid line",
+			"line", invalid.Line,
+			"column", invalid.Column,
+			"reason", invalid.Reason)
+	}
+	e.logger.Info("dry run: export finished without sending",
+		"valid", len(result.Valid),
+		"invalid", len(result.Invalid))
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/lineprotocol"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+)
+
+func TestExporter_Export_DryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.Error("should not be called")
+	}))
+	defer server.Close()
+
+	var results []DryRunResult
+	e := &Exporter{
+		opts: Options{URL: server.URL, APIToken: "token", DryRun: true, DryRunHandler: func(result DryRunResult) {
+			results = append(results, result)
+		}},
+		client: server.Client(),
+		logger: NewZapLogger(zap.L()),
+	}
+
+	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(11), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{
+			Name: "mylib",
+		}: {export.NewRecord(&desc, attribute.EmptySet(), ckpt.Aggregation(), intervalStart, intervalEnd)},
+	})
+
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	require.Equal(t, []DryRunResult{{Valid: []string{"name count,delta=11 " + intervalEndMillis}, Invalid: []InvalidLine{}}}, results)
+}
+
+func TestExporter_Export_DryRun_Invalid(t *testing.T) {
+	var results []DryRunResult
+	e := &Exporter{
+		opts: Options{DryRun: true, DryRunHandler: func(result DryRunResult) {
+			results = append(results, result)
+		}},
+		logger: NewZapLogger(zap.L()),
+	}
+
+	e.dryRun([]string{"name gauge,1", "name,dim=" + strings.Repeat("a", 251) + " gauge,1"})
+	require.Len(t, results, 1)
+	require.Equal(t, []string{"name gauge,1"}, results[0].Valid)
+	require.Len(t, results[0].Invalid, 1)
+	require.Equal(t, 10, results[0].Invalid[0].Column)
+	require.Equal(t, `value of dimension "dim" exceeds 250 characters`, results[0].Invalid[0].Reason)
+}
+
+func TestNewInvalidLine(t *testing.T) {
+	_, err := lineprotocol.ParseLine("name gauge,")
+	require.Error(t, err)
+	invalid := newInvalidLine("name gauge,", err)
+	require.Equal(t, "name gauge,", invalid.Line)
+	require.NotZero(t, invalid.Column)
+	require.NotEmpty(t, invalid.Reason)
+
+	require.Equal(t, InvalidLine{Line: "line", Reason: "other error"}, newInvalidLine("line", errors.New("other error")))
+}
+
+func TestExporter_Export_ParsedByLineProtocol(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e, err := NewExporter(Options{
+		Sink:                               NewWriterSink(buf),
+		DefaultDimensions:                  []dimensions.Dimension{NewDimension("path", `a b"c\d,e=f`)},
+		Units:                              UnitOptions{ConvertToCanonical: true, AppendSuffix: true, SendMetadata: true},
+		DisableDynatraceMetadataEnrichment: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), processortest.MultiInstrumentationLibraryReader(unitRecords(t))))
+
+	doc, err := lineprotocol.Parse(buf.String())
+	require.NoError(t, err, buf.String())
+	require.Len(t, doc.Metadata, 2)
+
+	types := map[string]lineprotocol.PayloadType{}
+	for _, line := range doc.Lines {
+		types[line.Key] = line.Payload.Type
+		path, ok := line.Dimension("path")
+		require.True(t, ok, line.Key)
+		require.Equal(t, `a b"c\d,e=f`, path)
+		require.Equal(t, intervalEnd.Truncate(time.Millisecond), line.Timestamp)
+	}
+	require.Equal(t, map[string]lineprotocol.PayloadType{
+		"transferred_bytes": lineprotocol.CountDelta,
+		"duration_seconds":  lineprotocol.Summary,
+		"queue":             lineprotocol.Gauge,
+	}, types)
+}
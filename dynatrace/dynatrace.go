@@ -15,28 +15,31 @@
 package dynatrace
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	dtMetric "github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
 	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/apiconstants"
 	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
-	"github.com/dynatrace-oss/dynatrace-metric-utils-go/oneagentenrichment"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/metric/export"
 	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
 	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
 	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-// NewExporter creates an exporter for the Dynatrace Metrics v2 API
+// NewExporter creates an exporter for the Dynatrace Metrics v2 API.
+// All options are validated up front and every problem found is reported in the returned error.
 func NewExporter(opts Options) (*Exporter, error) {
 	if opts.URL == "" {
 		opts.URL = apiconstants.GetDefaultOneAgentEndpoint()
@@ -44,27 +47,51 @@ func NewExporter(opts Options) (*Exporter, error) {
 	if opts.MetricNameFormatter == nil {
 		opts.MetricNameFormatter = defaultFormatter
 	}
-	if opts.Logger == nil {
-		opts.Logger = zap.NewNop()
+	if opts.Log == nil {
+		if opts.Logger != nil {
+			opts.Log = NewZapLogger(opts.Logger)
+		} else {
+			opts.Log = NewNopLogger()
+		}
+	}
+	if opts.ScopeNameDimension == "" {
+		opts.ScopeNameDimension = DefaultScopeNameDimension
+	}
+	if opts.ScopeVersionDimension == "" {
+		opts.ScopeVersionDimension = DefaultScopeVersionDimension
+	}
+	opts.ResourceAttributeMapping = copyMapping(opts.ResourceAttributeMapping)
+	if opts.ClockSkewLogThreshold == 0 {
+		opts.ClockSkewLogThreshold = DefaultClockSkewLogThreshold
 	}
 
-	client := &http.Client{}
-
-	staticDimensions := dimensions.NewNormalizedDimensionList(dimensions.NewDimension("dt.metrics.source", "opentelemetry"))
-
-	if !opts.DisableDynatraceMetadataEnrichment {
-		staticDimensions = dimensions.MergeLists(staticDimensions, oneagentenrichment.GetOneAgentMetadata())
+	if err := validateOptions(opts, opts.Log); err != nil {
+		return nil, fmt.Errorf("invalid exporter options: %w", err)
 	}
 
-	defaultDimensions := dimensions.NewNormalizedDimensionList(opts.DefaultDimensions...)
+	client := &http.Client{Timeout: opts.Timeout, Transport: newExporterTransport()}
+	filters, _ := compileFilterRules(opts.FilterRules)
+	anonymizer, _ := newAnonymizer(opts.Anonymization)
+	telemetry, err := newSelfTelemetry(opts.MeterProvider)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Exporter{
-		client:            client,
-		opts:              opts,
-		defaultDimensions: defaultDimensions,
-		staticDimensions:  staticDimensions,
-		logger:            opts.Logger,
-	}, nil
+	e := &Exporter{
+		client:     client,
+		opts:       opts,
+		filters:    filters,
+		anonymizer: anonymizer,
+		now:        time.Now,
+		telemetry:  telemetry,
+		logger:     opts.Log,
+	}
+	if opts.TracerProvider != nil {
+		e.tracer = opts.TracerProvider.Tracer(instrumentationName)
+	}
+	e.defaultDimensions = e.newDimensionList(opts.DefaultDimensions...)
+	e.startEnrichment(enrichmentSources(opts))
+	return e, nil
 }
 
 // Options contains options for configuring the exporter.
@@ -75,10 +102,90 @@ type Options struct {
 	DefaultDimensions                  []dimensions.Dimension
 	Logger                             *zap.Logger
 	DisableDynatraceMetadataEnrichment bool
+	// EnableKubernetesMetadataEnrichment adds the namespace, pod, node, workload and container
+	// of the current pod as dimensions to all data points.
+	EnableKubernetesMetadataEnrichment bool
+	// EnableCloudMetadataEnrichment adds the cloud provider, region, availability zone, account and host ID
+	// of AWS, GCP and Azure virtual machines as dimensions to all data points, using a CloudMetadataEnricher.
+	EnableCloudMetadataEnrichment bool
+	// Enrichers provide additional static dimensions for all data points.
+	// Later enrichers take precedence over earlier ones and over the built-in enrichments.
+	Enrichers []Enricher
+	// ResourceAttributeMapping maps resource attribute keys to the dimension keys they are exported as.
+	// If nil, resource attributes are only exported under their original keys.
+	// DefaultResourceAttributeMapping returns the mapping of Kubernetes attributes to Dynatrace dimensions.
+	ResourceAttributeMapping map[string]string
+	// DropMappedResourceAttributes drops the original keys of mapped resource attributes.
+	// By default, mapped resource attributes are exported under both keys.
+	DropMappedResourceAttributes bool
+	// AddScopeDimensions adds the name and version of the instrumentation scope as dimensions to each data point.
+	AddScopeDimensions bool
+	// ScopeNameDimension is the dimension key of the scope name. Defaults to DefaultScopeNameDimension.
+	ScopeNameDimension string
+	// ScopeVersionDimension is the dimension key of the scope version. Defaults to DefaultScopeVersionDimension.
+	ScopeVersionDimension string
+	// ScopeRules drop or rename the metrics of individual instrumentation scopes.
+	ScopeRules []ScopeRule
+	// FilterRules drop, keep or transform metrics before they are serialized. Rules are evaluated in order.
+	FilterRules []FilterRule
+	// DimensionLimits configures how dimension keys and values that exceed the maximum lengths are handled.
+	DimensionLimits DimensionLimits
+	// Anonymization hashes or redacts sensitive dimension values before they are normalized.
+	Anonymization Anonymization
+	// Units configures unit suffixes, conversion to canonical units and unit metadata.
+	Units UnitOptions
+	// DropExpiredLines drops lines with timestamps older than IngestAcceptanceWindow,
+	// which would otherwise be rejected by the ingest API.
+	DropExpiredLines bool
+	// CorrectClockSkew estimates the offset of the local clock from the Date header of ingest API responses
+	// and shifts the timestamps of subsequent lines by the smoothed estimate.
+	CorrectClockSkew bool
+	// ClockSkewLogThreshold is the change of the clock skew correction that is logged.
+	// Defaults to DefaultClockSkewLogThreshold.
+	ClockSkewLogThreshold time.Duration
+	// UpDownSumMode selects whether non-monotonic sums are exported as gauges of their level
+	// or as count,delta lines of their change. Defaults to UpDownSumAsGauge.
+	UpDownSumMode UpDownSumMode
+	// InvalidValues selects whether NaN and infinite values and negative counter deltas are dropped or clamped.
+	// Defaults to DropInvalidValues.
+	InvalidValues InvalidValuePolicy
+	// MeterProvider receives the metrics the exporter records about itself, such as lines serialized and dropped,
+	// lines accepted and rejected by the ingest API, and request latencies. If nil, no metrics are recorded.
+	MeterProvider metric.MeterProvider
+	// TracerProvider creates a span for each export and a child span for each batch sent.
+	// If nil, no spans are created.
+	TracerProvider trace.TracerProvider
+	// Log is the logger of the exporter. Adapters for zap, slog and logr are provided by NewZapLogger,
+	// NewSlogLogger and NewLogrLogger. It takes precedence over Logger. If neither is set, nothing is logged.
+	Log Logger
+
+	// Compression selects the content encoding of requests to the ingest API.
+	Compression Compression
+	// Timeout limits the duration of a single request to the ingest API.
+	// A zero value means no timeout.
+	Timeout time.Duration
+	// Sink receives the serialized lines instead of the ingest API.
+	// If nil, lines are posted to URL.
+	Sink Sink
+	// DryRun validates the serialized lines against the line protocol instead of sending them.
+	DryRun bool
+	// DryRunHandler receives the result of each export in dry-run mode.
+	// If nil, the lines and validation failures are logged.
+	DryRunHandler func(DryRunResult)
 
 	MetricNameFormatter func(namespace, name string) string
 }
 
+// Compression selects the content encoding used for requests to the ingest API.
+type Compression int
+
+const (
+	// NoCompression sends the payload as plain text.
+	NoCompression Compression = iota
+	// GzipCompression compresses the payload using gzip.
+	GzipCompression
+)
+
 // Create a new dimension for use in the DefaultDimensions option
 func NewDimension(key, value string) dimensions.Dimension {
 	return dimensions.NewDimension(key, value)
@@ -87,17 +194,33 @@ func NewDimension(key, value string) dimensions.Dimension {
 // Exporter forwards metrics to a Dynatrace agent
 type Exporter struct {
 	opts              Options
-	defaultDimensions dimensions.NormalizedDimensionList
-	staticDimensions  dimensions.NormalizedDimensionList
+	defaultDimensions dimensionList
+	filters           []compiledFilterRule
+	truncations       truncationCounter
+	anonymizer        *anonymizer
+	now               func() time.Time
+	clockSkew         clockSkew
+	upDownSums        upDownSums
+	unsupported       unsupportedCounter
+	sanitized         sanitizeCounter
+	telemetry         *selfTelemetry
+	tracer            trace.Tracer
 	client            *http.Client
-	logger            *zap.Logger
+	logger            Logger
+
+	staticMu         sync.RWMutex
+	staticDimensions dimensionList
+	enriched         []dimensionList
+	stopEnrichment   context.CancelFunc
+	enrichmentDone   sync.WaitGroup
 }
 
 func defaultFormatter(namespace, name string) string {
 	return name
 }
 
-// TemporalityFor returns delta for histograms and monotonic counters, else cumulative
+// TemporalityFor returns delta for histograms and monotonic counters, else cumulative.
+// Non-monotonic sums are requested as delta if they are exported as count,delta lines.
 func (e *Exporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
 	if kind == aggregation.HistogramKind {
 		return aggregation.DeltaTemporality
@@ -107,17 +230,46 @@ func (e *Exporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind
 		return aggregation.DeltaTemporality
 	}
 
+	if desc.InstrumentKind().Adding() && e.opts.UpDownSumMode == UpDownSumAsDelta {
+		return aggregation.DeltaTemporality
+	}
+
 	return aggregation.CumulativeTemporality
 }
 
 // Export a batch of metrics
 func (e *Exporter) Export(ctx context.Context, res *resource.Resource, reader export.InstrumentationLibraryReader) error {
+	ctx, span := e.startSpan(ctx, "dynatrace.Export")
+	defer span.End()
+
 	lines := []string{}
+	staticDimensions := e.getStaticDimensions()
+	resourceDimensions := e.newDimensionList(e.resourceDimensions(res)...)
+	defer e.logTruncations()
+
+	expired := 0
+	unitMetadata := map[string]bool{}
+	addUnitMetadata := func(name string, unit resolvedUnit, count bool) {
+		if !e.opts.Units.SendMetadata {
+			return
+		}
+		if line := e.unitMetadataLine(name, unit, count); line != "" && !unitMetadata[line] {
+			unitMetadata[line] = true
+			lines = append(lines, line)
+		}
+	}
 
 	_ = reader.ForEach(func(l instrumentation.Library, reader export.Reader) error {
+		rule := e.scopeRule(l)
+		if rule != nil && rule.Drop {
+			return nil
+		}
+		scopeDimensions := e.scopeDimensions(l)
+
 		return reader.ForEach(e, func(record export.Record) error {
+			name := rule.metricName(record.Descriptor().Name())
 			dims := []dimensions.Dimension{}
-			iter := attribute.NewMergeIterator(record.Attributes(), res.Set())
+			iter := record.Attributes().Iter()
 
 			for iter.Next() {
 				label := iter.Label()
@@ -127,131 +279,198 @@ func (e *Exporter) Export(ctx context.Context, res *resource.Resource, reader ex
 				}
 			}
 
+			filtered := e.applyFilters(name, l.Name, record.Descriptor().InstrumentKind(), dims)
+			if filtered.drop {
+				e.telemetry.lineDropped(ctx, DroppedFiltered)
+				return nil
+			}
+			name = filtered.name
+			unit := e.resolveUnit(string(record.Descriptor().Unit()))
+			name = unit.withUnitSuffix(name)
+
 			agg := record.Aggregation()
 
-			dtDimensions := dimensions.MergeLists(
+			dtDimensions := filtered.apply(
 				e.defaultDimensions,
-				dimensions.NewNormalizedDimensionList(dims...),
-				e.staticDimensions,
+				resourceDimensions,
+				e.newDimensionList(dims...),
+				scopeDimensions,
+				staticDimensions,
 			)
 
-			if hist, ok := agg.(aggregation.Histogram); ok {
-				summary, err := summaryFromHistogram(hist, record.Descriptor().NumberKind())
-
+			if summary, ok, err := e.summaryFor(name, agg, record.Descriptor().NumberKind(), unit.factor); ok {
 				if err != nil {
-					e.logger.Sugar().Errorw("error converting histogram to dt summary",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error converting aggregation to dt summary",
+						"name", name,
 						"error", err)
 					return nil
 				}
 
-				if summary != nil {
+				if summary != nil && e.expired(record.EndTime()) {
+					expired++
+					e.telemetry.lineDropped(ctx, DroppedExpired)
+				} else if summary != nil {
 					metric, err := dtMetric.NewMetric(
-						record.Descriptor().Name(),
+						name,
 						dtMetric.WithPrefix(e.opts.Prefix),
 						dtMetric.WithDimensions(dtDimensions),
 						summary,
+						dtMetric.WithTimestamp(e.correctTimestamp(record.EndTime())),
 					)
 
 					if err != nil {
-						e.logger.Sugar().Errorw("error creating summary metric from histogram summary",
-							"name", record.Descriptor().Name(),
+						e.logger.Error("error creating summary metric",
+							"name", name,
 							"error", err)
 						return nil
 					}
 
 					line, err := metric.Serialize()
 					if err != nil {
-						e.logger.Sugar().Errorw("error serializing histogram summary metric",
-							"name", record.Descriptor().Name(),
+						e.logger.Error("error serializing summary metric",
+							"name", name,
 							"error", err)
 					}
 					if line != "" {
 						lines = append(lines, line)
+						addUnitMetadata(name, unit, false)
 					}
 				}
 			} else if sum, ok := agg.(aggregation.Sum); ok {
-				valOpt, err := valueOptForSum(sum, record.Descriptor().InstrumentKind().Monotonic(), record.Descriptor().NumberKind())
+				monotonic := record.Descriptor().InstrumentKind().Monotonic()
+				var valOpt dtMetric.MetricOption
+				var err error
+				if monotonic {
+					valOpt, err = e.valueOptForSum(name, sum, true, record.Descriptor().NumberKind(), unit.factor)
+				} else {
+					valOpt, err = e.valueOptForUpDownSum(name, record, sum, seriesKey(name, dtDimensions), unit.factor)
+				}
 
 				if err != nil {
-					e.logger.Sugar().Errorw("error creating dtMetric option for sum",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error creating dtMetric option for sum",
+						"name", name,
 						"error", err)
 					return nil
 				}
+				if valOpt == nil {
+					return nil
+				}
+
+				if e.expired(record.EndTime()) {
+					expired++
+					e.telemetry.lineDropped(ctx, DroppedExpired)
+					return nil
+				}
 
 				metric, err := dtMetric.NewMetric(
-					record.Descriptor().Name(),
+					name,
 					dtMetric.WithPrefix(e.opts.Prefix),
 					dtMetric.WithDimensions(dtDimensions),
 					valOpt,
+					dtMetric.WithTimestamp(e.correctTimestamp(record.EndTime())),
 				)
 
 				if err != nil {
-					e.logger.Sugar().Errorw("error creating count metric from sum",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error creating count metric from sum",
+						"name", name,
 						"error", err)
 					return nil
 				}
 
 				line, err := metric.Serialize()
 				if err != nil {
-					e.logger.Sugar().Errorw("error serializing count metric",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error serializing count metric",
+						"name", name,
 						"error", err)
 				}
 				if line != "" {
 					lines = append(lines, line)
+					addUnitMetadata(name, unit, monotonic || e.opts.UpDownSumMode == UpDownSumAsDelta)
 				}
 			} else if agg, ok := agg.(aggregation.LastValue); ok {
 				lastValue, ts, err := agg.LastValue()
 				if err != nil {
-					e.logger.Sugar().Errorw("error converting sum to dt counter",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error converting sum to dt counter",
+						"name", name,
 						"error", err)
 					return nil
 				}
+				lastValue, ok := e.sanitizeValue(name, lastValue, record.Descriptor().NumberKind(), false)
+				if !ok {
+					return nil
+				}
+
+				if e.expired(ts) {
+					expired++
+					e.telemetry.lineDropped(ctx, DroppedExpired)
+					return nil
+				}
 
 				metric, err := dtMetric.NewMetric(
-					record.Descriptor().Name(),
+					name,
 					dtMetric.WithPrefix(e.opts.Prefix),
 					dtMetric.WithDimensions(dtDimensions),
-					dtMetric.WithFloatGaugeValue(lastValue.CoerceToFloat64(record.Descriptor().NumberKind())),
-					dtMetric.WithTimestamp(ts),
+					gaugeValue(lastValue, record.Descriptor().NumberKind(), unit.factor),
+					dtMetric.WithTimestamp(e.correctTimestamp(ts)),
 				)
 
 				if err != nil {
-					e.logger.Sugar().Errorw("error creating gauge metric from last value",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error creating gauge metric from last value",
+						"name", name,
 						"error", err)
 				}
 
 				line, err := metric.Serialize()
 				if err != nil {
-					e.logger.Sugar().Errorw("error serializing gauge metric",
-						"name", record.Descriptor().Name(),
+					e.logger.Error("error serializing gauge metric",
+						"name", name,
 						"error", err)
 				}
 				if line != "" {
 					lines = append(lines, line)
+					addUnitMetadata(name, unit, false)
 				}
 			} else {
-				e.logger.Sugar().Errorw("Unsupported aggregation",
-					"aggregator", agg.Kind().String())
+				e.telemetry.lineDropped(ctx, DroppedUnsupportedAggregation)
+				if e.unsupported.add(record.Descriptor().Name()) {
+					e.logger.Warn("unsupported aggregation, the instrument is not exported",
+						"instrument", record.Descriptor().Name(),
+						"aggregator", record.Aggregation().Kind().String())
+				}
 			}
 			return nil
 		})
 	})
+	e.upDownSums.finishCollection()
+
+	if expired > 0 {
+		e.logger.Warn("dropped lines older than the ingest acceptance window",
+			"count", expired,
+			"window", IngestAcceptanceWindow)
+	}
+
+	e.telemetry.serialized(ctx, len(lines))
+	span.SetAttributes(linesKey.Int(len(lines)))
+
+	if e.opts.DryRun {
+		e.dryRun(lines)
+		return nil
+	}
 
 	limit := apiconstants.GetPayloadLinesLimit()
+	batches := 0
+	defer func() { span.SetAttributes(batchesKey.Int(batches)) }()
 	for i := 0; i < len(lines); i += limit {
 		batch := lines[i:min(i+limit, len(lines))]
 
 		output := strings.Join(batch, "\n")
 		if output != "" {
-			err := e.send(output)
+			batches++
+			err := e.sendBatch(ctx, output, len(batch))
 			if err != nil {
-				return fmt.Errorf("error processing data:, %s", err.Error())
+				err = fmt.Errorf("error processing data:, %s", err.Error())
+				recordSpanError(span, err)
+				return err
 			}
 		}
 	}
@@ -259,61 +478,63 @@ func (e *Exporter) Export(ctx context.Context, res *resource.Resource, reader ex
 	return nil
 }
 
-func (e *Exporter) send(message string) error {
-	e.logger.Debug("Sending lines to Dynatrace\n" + message)
-	req, err := http.NewRequest("POST", e.opts.URL, bytes.NewBufferString(message))
-	if err != nil {
-		return fmt.Errorf("dynatrace error while creating HTTP request: %s", err.Error())
-	}
-
-	req.Header.Add("Content-Type", "text/plain; charset=UTF-8")
-	req.Header.Add("Authorization", "Api-Token "+e.opts.APIToken)
-	req.Header.Add("User-Agent", "opentelemetry-metric-go")
-
-	resp, err := e.client.Do(req)
+func (e *Exporter) send(ctx context.Context, message string) error {
+	e.logger.Debug("sending lines to Dynatrace", "lines", message)
+	sent := e.currentTime()
+	resp, size, err := postLines(ctx, e.client, e.opts.URL, e.opts.APIToken, e.opts.Compression, message)
 	if err != nil {
-		return fmt.Errorf("error sending HTTP request: %s", err.Error())
+		return err
 	}
 	defer resp.Body.Close()
+	received := e.currentTime()
+	e.observeServerDate(resp.Header, sent, received)
+	e.telemetry.request(ctx, resp.StatusCode, received.Sub(sent), size)
+	trace.SpanFromContext(ctx).SetAttributes(statusCodeKey.Int(resp.StatusCode), contentLenKey.Int(size))
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("error while receiving HTTP response: %s", err.Error())
-	}
-
-	responseBody := metricsResponse{}
-	if err := json.Unmarshal(bodyBytes, &responseBody); err != nil {
-		e.logger.Error(fmt.Sprintf("failed to unmarshal response: %s", err.Error()))
-	} else {
-		e.logger.Debug(fmt.Sprintf("Exported %d lines to Dynatrace", responseBody.Ok))
-
-		if responseBody.Invalid > 0 {
-			e.logger.Debug(fmt.Sprintf("Failed to export %d lines to Dynatrace", responseBody.Invalid))
-		}
-
-		if responseBody.Error != "" {
-			e.logger.Error(fmt.Sprintf("Error from Dynatrace: %s", responseBody.Error))
-		}
-	}
+	return handleIngestResponse(ctx, resp, e.logger, e.telemetry)
+}
 
-	if !(resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted) {
-		return fmt.Errorf("request failed with response code:, %d", resp.StatusCode)
+// sink returns the configured sink, or a sink posting to the ingest API with the exporter's client if there is none.
+func (e *Exporter) sink() Sink {
+	if e.opts.Sink != nil {
+		return e.opts.Sink
 	}
-
-	return nil
+	return sendFunc(e.send)
 }
 
 // Close the exporter
 func (e *Exporter) Close() error {
+	if e.stopEnrichment != nil {
+		e.stopEnrichment()
+		e.enrichmentDone.Wait()
+	}
 	e.client = nil
+	if closer, ok := e.opts.Sink.(io.Closer); ok {
+		return closer.Close()
+	}
 	return nil
 }
 
 // Response from Dynatrace is expected to be in JSON format
 type metricsResponse struct {
-	Ok      int64  `json:"linesOk"`
-	Invalid int64  `json:"linesInvalid"`
-	Error   string `json:"error"`
+	Ok      int64                 `json:"linesOk"`
+	Invalid int64                 `json:"linesInvalid"`
+	Error   *metricsResponseError `json:"error"`
+}
+
+type metricsResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// UnmarshalJSON accepts both a plain error message and the error object returned by the ingest API.
+func (r *metricsResponseError) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &r.Message)
+	}
+
+	type plain metricsResponseError
+	return json.Unmarshal(data, (*plain)(r))
 }
 
 func min(a, b int) int {
@@ -15,12 +15,15 @@
 package dynatrace
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -42,6 +45,8 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.uber.org/zap"
+
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/dynatracetest"
 )
 
 var (
@@ -49,11 +54,13 @@ var (
 
 	intervalStart = time.Now()
 	intervalEnd   = intervalStart.Add(time.Hour)
+	// intervalEndMillis is the timestamp of lines created from records ending at intervalEnd.
+	intervalEndMillis = strconv.FormatInt(intervalEnd.UnixNano()/int64(time.Millisecond), 10)
 )
 
 func TestNewExporter(t *testing.T) {
 	t.Run("construct with URL", func(t *testing.T) {
-		got, err := NewExporter(Options{URL: "https://example.com"})
+		got, err := NewExporter(Options{URL: "https://example.com", APIToken: "token"})
 		if err != nil {
 			t.Error("Should not return error")
 		}
@@ -112,10 +119,10 @@ func TestExporter_send(t *testing.T) {
 		e := &Exporter{
 			opts:   Options{URL: server.URL, APIToken: "token"},
 			client: server.Client(),
-			logger: zap.L(),
+			logger: NewZapLogger(zap.L()),
 		}
 
-		e.send("body text")
+		e.send(context.Background(), "body text")
 	})
 
 	t.Run("posts requests", func(t *testing.T) {
@@ -136,13 +143,38 @@ func TestExporter_send(t *testing.T) {
 		e := &Exporter{
 			opts:   Options{URL: server.URL, APIToken: "token"},
 			client: server.Client(),
-			logger: zap.L(),
+			logger: NewZapLogger(zap.L()),
 		}
 
-		e.send("body text")
+		e.send(context.Background(), "body text")
 	})
 }
 
+func TestExporter_send_Gzip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if enc := req.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Errorf("Expected Content-Encoding %#v to equal %#v", enc, "gzip")
+		}
+		zr, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+
+		if string(body) != "body text" {
+			t.Errorf("Expected body %#v to equal %s", string(body), "body text")
+		}
+		rw.Write([]byte(`OK`))
+	}))
+	defer server.Close()
+	e := &Exporter{
+		opts:   Options{URL: server.URL, APIToken: "token", Compression: GzipCompression},
+		client: server.Client(),
+		logger: NewZapLogger(zap.L()),
+	}
+
+	require.NoError(t, e.send(context.Background(), "body text"))
+}
+
 func TestExporter_TemporalityFor(t *testing.T) {
 	e := &Exporter{}
 	if temporality := e.TemporalityFor(&sdkapi.Descriptor{}, aggregation.HistogramKind); temporality != aggregation.DeltaTemporality {
@@ -179,7 +211,7 @@ func TestExporter_Export_Empty(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
@@ -203,7 +235,7 @@ func TestExporter_Export_Authorization(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -231,7 +263,7 @@ func TestExporter_Export_Counter(t *testing.T) {
 			t.Error("Failed to read body")
 		}
 
-		expect := "name count,delta=11"
+		expect := "name count,delta=11 " + intervalEndMillis
 		if string(body) != expect {
 			t.Errorf("Expected body %#v to equal %#v", string(body), expect)
 		}
@@ -242,7 +274,7 @@ func TestExporter_Export_Counter(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -270,7 +302,7 @@ func TestExporter_Export_UpDownCounter(t *testing.T) {
 			t.Error("Failed to read body")
 		}
 
-		expect := "name gauge,18"
+		expect := "name gauge,18 " + intervalEndMillis
 		if string(body) != expect {
 			t.Errorf("Expected body %#v to equal %#v", string(body), expect)
 		}
@@ -281,7 +313,7 @@ func TestExporter_Export_UpDownCounter(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.UpDownCounterInstrumentKind, number.Float64Kind)
@@ -320,7 +352,7 @@ func TestExporter_Export_Gauge(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.GaugeObserverInstrumentKind, number.Float64Kind)
@@ -348,7 +380,7 @@ func TestExporter_Export_Histogram(t *testing.T) {
 			t.Error("Failed to read body")
 		}
 
-		expect := "name gauge,min=2,max=8,sum=21,count=4"
+		expect := "name gauge,min=2,max=8,sum=21,count=4 " + intervalEndMillis
 		if expect != string(body) {
 			t.Errorf("Expected body %#v to equal %#v", string(body), expect)
 		}
@@ -359,7 +391,7 @@ func TestExporter_Export_Histogram(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.GaugeObserverInstrumentKind, number.Float64Kind)
@@ -389,7 +421,7 @@ func TestExporter_Export_Prefix(t *testing.T) {
 			t.Error("Failed to read body")
 		}
 
-		expect := "someprefix.name count,delta=11"
+		expect := "someprefix.name count,delta=11 " + intervalEndMillis
 		if string(body) != expect {
 			t.Errorf("Expected body %#v to equal %#v", string(body), expect)
 		}
@@ -400,7 +432,7 @@ func TestExporter_Export_Prefix(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token", Prefix: "someprefix"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -440,8 +472,8 @@ func TestExporter_Export_Counter_DefaultDims(t *testing.T) {
 	e := &Exporter{
 		opts:              Options{URL: server.URL, APIToken: "token"},
 		client:            server.Client(),
-		logger:            zap.L(),
-		defaultDimensions: dimensions.NewNormalizedDimensionList(dimensions.NewDimension("from", "default")),
+		logger:            NewZapLogger(zap.L()),
+		defaultDimensions: normalizedDimensionList(dimensions.NewDimension("from", "default")),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -481,7 +513,7 @@ func TestExporter_Export_Counter_MetricDims(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -522,9 +554,9 @@ func TestExporter_Export_Counter_StaticDims(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 
-		staticDimensions: dimensions.NewNormalizedDimensionList(dimensions.NewDimension("from", "static")),
+		staticDimensions: normalizedDimensionList(dimensions.NewDimension("from", "static")),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -565,7 +597,7 @@ func TestExporter_Export_NonStringDims(t *testing.T) {
 	e := &Exporter{
 		opts:   Options{URL: server.URL, APIToken: "token"},
 		client: server.Client(),
-		logger: zap.L(),
+		logger: NewZapLogger(zap.L()),
 	}
 
 	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
@@ -590,3 +622,84 @@ func TestExporter_Export_NonStringDims(t *testing.T) {
 
 	e.Export(context.Background(), resource.Empty(), reader)
 }
+
+func TestExporter_Export_FakeIngest(t *testing.T) {
+	server := dynatracetest.NewServer("token")
+	defer server.Close()
+	server.RejectLines(func(m dynatracetest.Metric) bool { return m.Dimensions["from"] == "rejected" })
+
+	e, err := NewExporter(Options{
+		URL:                                server.URL,
+		APIToken:                           "token",
+		Compression:                        GzipCompression,
+		DefaultDimensions:                  []dimensions.Dimension{NewDimension("from", "default")},
+		DisableDynatraceMetadataEnrichment: true,
+	})
+	require.NoError(t, err)
+
+	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(11), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+
+	attrs := attribute.NewSet(attribute.String("from", "rejected"))
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{
+			Name: "mylib",
+		}: {
+			export.NewRecord(&desc, attribute.EmptySet(), ckpt.Aggregation(), intervalStart, intervalEnd),
+			export.NewRecord(&desc, &attrs, ckpt.Aggregation(), intervalStart, intervalEnd),
+		},
+	})
+
+	require.Error(t, e.Export(context.Background(), resource.Empty(), reader))
+
+	m := server.AssertMetric(t, "name", map[string]string{"from": "default", "dt.metrics.source": "opentelemetry"})
+	require.Equal(t, "count,delta=11", m.Payload.String())
+	server.AssertNoMetric(t, "name", map[string]string{"from": "rejected"})
+}
+
+// counterRecord returns a record of a float counter with the given delta.
+func counterRecord(t *testing.T, name string, value float64, attrs ...attribute.KeyValue) export.Record {
+	t.Helper()
+	desc := metrictest.NewDescriptor(name, sdkapi.CounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(value), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+	set := attribute.NewSet(attrs...)
+	return export.NewRecord(&desc, &set, ckpt.Aggregation(), intervalStart, intervalEnd)
+}
+
+// exportLines exports the records in dry-run mode and returns the serialized lines.
+func exportLines(t *testing.T, opts Options, res *resource.Resource, records map[instrumentation.Library][]export.Record) []string {
+	t.Helper()
+	lines := []string{}
+	opts.DryRun = true
+	opts.DisableDynatraceMetadataEnrichment = true
+	opts.DryRunHandler = func(result DryRunResult) {
+		require.Empty(t, result.Invalid)
+		lines = append(lines, result.Valid...)
+	}
+
+	e, err := NewExporter(opts)
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Export(context.Background(), res, processortest.MultiInstrumentationLibraryReader(records)))
+	return lines
+}
+
+// sortDimensionsOfLines sorts the dimensions of each line, since their order is not deterministic.
+func sortDimensionsOfLines(lines []string) []string {
+	sorted := make([]string, 0, len(lines))
+	for _, line := range lines {
+		parts := strings.SplitN(line, " ", 2)
+		keyAndDims := strings.Split(parts[0], ",")
+		sort.Strings(keyAndDims[1:])
+		sorted = append(sorted, strings.Join(keyAndDims, ",")+" "+parts[1])
+	}
+	return sorted
+}
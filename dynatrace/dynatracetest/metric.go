@@ -0,0 +1,65 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatracetest
+
+import (
+	"time"
+
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/lineprotocol"
+)
+
+// Metric is a single line received by the fake ingest endpoint.
+type Metric struct {
+	// Line is the raw line as it was received.
+	Line string
+	// Key is the metric key including the prefix.
+	Key string
+	// Dimensions maps dimension keys to their unescaped values.
+	Dimensions map[string]string
+	// Payload is the parsed value part of the line.
+	Payload lineprotocol.Payload
+	// Timestamp is the timestamp of the line, or the zero time if the line has none.
+	Timestamp time.Time
+}
+
+// HasDimensions reports whether the metric has all of the given dimensions.
+// The metric may have additional dimensions.
+func (m Metric) HasDimensions(dims map[string]string) bool {
+	for k, v := range dims {
+		if actual, ok := m.Dimensions[k]; !ok || actual != v {
+			return false
+		}
+	}
+	return true
+}
+
+func parseMetric(raw string) (Metric, error) {
+	line, err := lineprotocol.ParseLine(raw)
+	if err != nil {
+		return Metric{}, err
+	}
+
+	m := Metric{
+		Line:       raw,
+		Key:        line.Key,
+		Dimensions: map[string]string{},
+		Payload:    line.Payload,
+		Timestamp:  line.Timestamp,
+	}
+	for _, dim := range line.Dimensions {
+		m.Dimensions[dim.Key] = dim.Value
+	}
+	return m, nil
+}
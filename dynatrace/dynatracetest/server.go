@@ -0,0 +1,317 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dynatracetest provides an in-process fake of the Dynatrace metrics ingest API
+// for testing code that exports metrics to Dynatrace.
+package dynatracetest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/lineprotocol"
+)
+
+// Server is a fake ingest endpoint. It checks authentication, content type and encoding of each request,
+// parses the received lines and answers with the same JSON responses as the Dynatrace metrics ingest API.
+// Accepted lines are recorded and can be inspected with the assertion helpers.
+type Server struct {
+	// URL is the ingest endpoint of the server, to be used as exporter URL.
+	URL string
+
+	token string
+	srv   *httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	metrics  []Metric
+	faults   []Fault
+	latency  time.Duration
+	reject   func(Metric) bool
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Header http.Header
+	// Body is the request body after decompression.
+	Body string
+	// StatusCode is the status code the server responded with.
+	StatusCode int
+}
+
+// Fault describes a failure the server simulates for a single request.
+type Fault struct {
+	// StatusCode is returned instead of processing the request. If zero, the request is processed normally.
+	StatusCode int
+	// RetryAfter is sent in the Retry-After header if non-zero.
+	RetryAfter time.Duration
+	// Latency delays the response.
+	Latency time.Duration
+}
+
+type invalidLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type responseError struct {
+	Code         int           `json:"code"`
+	Message      string        `json:"message"`
+	InvalidLines []invalidLine `json:"invalidLines,omitempty"`
+}
+
+type response struct {
+	Ok       int            `json:"linesOk"`
+	Invalid  int            `json:"linesInvalid"`
+	Error    *responseError `json:"error"`
+	Warnings interface{}    `json:"warnings"`
+}
+
+// NewServer starts a fake ingest endpoint that requires the given API token.
+// An empty token disables the authentication check, like the local OneAgent endpoint does.
+// The server must be closed with Close.
+func NewServer(token string) *Server {
+	s := &Server{token: token}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.srv.URL + "/api/v2/metrics/ingest"
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns an HTTP client configured for the server.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// InjectFaults queues faults. Each subsequent request consumes one fault until the queue is empty.
+func (s *Server) InjectFaults(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// RejectLines makes the server reject every line for which reject returns true,
+// resulting in a partially accepted request. Passing nil accepts all valid lines again.
+func (s *Server) RejectLines(reject func(Metric) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+// Requests returns all requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Metrics returns all accepted lines received so far.
+func (s *Server) Metrics() []Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Metric{}, s.metrics...)
+}
+
+// Reset forgets all recorded requests and metrics as well as pending faults.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.metrics = nil
+	s.faults = nil
+}
+
+// FindMetrics returns the accepted metrics with the given key that have at least the given dimensions.
+func (s *Server) FindMetrics(key string, dims map[string]string) []Metric {
+	found := []Metric{}
+	for _, m := range s.Metrics() {
+		if m.Key == key && m.HasDimensions(dims) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// AssertMetric fails the test unless exactly one accepted metric has the given key and at least the given dimensions.
+// It returns the matching metric.
+func (s *Server) AssertMetric(t testing.TB, key string, dims map[string]string) Metric {
+	t.Helper()
+	found := s.FindMetrics(key, dims)
+	if len(found) != 1 {
+		t.Errorf("expected exactly one metric %q with dimensions %v, found %d in %v", key, dims, len(found), s.lines())
+		return Metric{}
+	}
+	return found[0]
+}
+
+// AssertNoMetric fails the test if any accepted metric has the given key and at least the given dimensions.
+func (s *Server) AssertNoMetric(t testing.TB, key string, dims map[string]string) {
+	t.Helper()
+	if found := s.FindMetrics(key, dims); len(found) > 0 {
+		t.Errorf("expected no metric %q with dimensions %v, found %v", key, dims, found)
+	}
+}
+
+func (s *Server) lines() []string {
+	lines := []string{}
+	for _, m := range s.Metrics() {
+		lines = append(lines, m.Line)
+	}
+	return lines
+}
+
+func (s *Server) handle(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	fault := Fault{}
+	if len(s.faults) > 0 {
+		fault, s.faults = s.faults[0], s.faults[1:]
+	}
+	latency := s.latency + fault.Latency
+	reject := s.reject
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	body, err := readBody(req)
+	record := Request{Header: req.Header.Clone(), Body: body}
+	status, resp, metrics := s.process(req, body, err, fault, reject)
+	record.StatusCode = status
+
+	s.mu.Lock()
+	s.requests = append(s.requests, record)
+	s.metrics = append(s.metrics, metrics...)
+	s.mu.Unlock()
+
+	if fault.RetryAfter > 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Seconds())))
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+func (s *Server) process(req *http.Request, body string, bodyErr error, fault Fault, reject func(Metric) bool) (int, response, []Metric) {
+	if fault.StatusCode != 0 {
+		return errorResponse(fault.StatusCode, "injected fault")
+	}
+	if req.Method != http.MethodPost {
+		return errorResponse(http.StatusMethodNotAllowed, "only POST is supported")
+	}
+	if s.token != "" && req.Header.Get("Authorization") != "Api-Token "+s.token {
+		return errorResponse(http.StatusUnauthorized, "missing or invalid authorization")
+	}
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "text/plain") {
+		return errorResponse(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", req.Header.Get("Content-Type")))
+	}
+	if bodyErr != nil {
+		return errorResponse(http.StatusBadRequest, bodyErr.Error())
+	}
+
+	resp := response{}
+	accepted := []Metric{}
+	invalid := []invalidLine{}
+	metadata := 0
+	for i, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			if _, err := lineprotocol.ParseMetadata(line); err != nil {
+				if syntaxErr, ok := err.(*lineprotocol.SyntaxError); ok {
+					syntaxErr.Line = i + 1
+				}
+				invalid = append(invalid, invalidLine{Line: i + 1, Error: err.Error()})
+			} else {
+				metadata++
+			}
+			continue
+		}
+
+		m, err := parseMetric(line)
+		if syntaxErr, ok := err.(*lineprotocol.SyntaxError); ok {
+			syntaxErr.Line = i + 1
+		}
+		if err == nil && reject != nil && reject(m) {
+			err = fmt.Errorf("line rejected by test")
+		}
+		if err != nil {
+			invalid = append(invalid, invalidLine{Line: i + 1, Error: err.Error()})
+			continue
+		}
+		accepted = append(accepted, m)
+	}
+
+	resp.Ok = len(accepted) + metadata
+	resp.Invalid = len(invalid)
+	if len(invalid) == 0 {
+		return http.StatusAccepted, resp, accepted
+	}
+
+	resp.Error = &responseError{
+		Code:         http.StatusBadRequest,
+		Message:      fmt.Sprintf("%d invalid lines", len(invalid)),
+		InvalidLines: invalid,
+	}
+	return http.StatusBadRequest, resp, accepted
+}
+
+func errorResponse(status int, message string) (int, response, []Metric) {
+	return status, response{Error: &responseError{Code: status, Message: message}}, nil
+}
+
+func readBody(req *http.Request) (string, error) {
+	var reader io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return "", fmt.Errorf("invalid gzip body: %s", err.Error())
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("cannot read body: %s", err.Error())
+	}
+	return string(body), nil
+}
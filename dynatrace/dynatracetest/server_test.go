@@ -0,0 +1,122 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatracetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func post(t *testing.T, s *Server, token, body string, gzipped bool) (*http.Response, response) {
+	t.Helper()
+	payload := &bytes.Buffer{}
+	if gzipped {
+		zw := gzip.NewWriter(payload)
+		_, err := zw.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	} else {
+		payload.WriteString(body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, payload)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+	req.Header.Set("Authorization", "Api-Token "+token)
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	parsed := response{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&parsed))
+	return resp, parsed
+}
+
+func TestServer_AcceptsLines(t *testing.T) {
+	s := NewServer("token")
+	defer s.Close()
+
+	resp, body := post(t, s, "token", "name,dim=a\\ b count,delta=11\nother gauge,2 1656061000000\n", true)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, 2, body.Ok)
+	require.Nil(t, body.Error)
+
+	m := s.AssertMetric(t, "name", map[string]string{"dim": "a b"})
+	require.Equal(t, "count,delta=11", m.Payload.String())
+	other := s.AssertMetric(t, "other", nil)
+	require.Equal(t, time.Unix(1656061000, 0), other.Timestamp)
+	s.AssertNoMetric(t, "name", map[string]string{"dim": "c"})
+	require.Equal(t, "gzip", s.Requests()[0].Header.Get("Content-Encoding"))
+}
+
+func TestServer_RejectsUnauthorized(t *testing.T) {
+	s := NewServer("token")
+	defer s.Close()
+
+	resp, body := post(t, s, "wrong", "name gauge,1", false)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, http.StatusUnauthorized, body.Error.Code)
+	require.Empty(t, s.Metrics())
+}
+
+func TestServer_PartialReject(t *testing.T) {
+	s := NewServer("token")
+	defer s.Close()
+	s.RejectLines(func(m Metric) bool { return strings.HasPrefix(m.Key, "drop") })
+
+	resp, body := post(t, s, "token", "keep gauge,1\ndrop.me gauge,1\nbroken", false)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, 1, body.Ok)
+	require.Equal(t, 2, body.Invalid)
+	require.Equal(t, []invalidLine{
+		{Line: 2, Error: "line rejected by test"},
+		{Line: 3, Error: "line 3, column 7: unexpected end of line, expected ' '"},
+	}, body.Error.InvalidLines)
+	s.AssertMetric(t, "keep", nil)
+}
+
+func TestServer_InjectFaults(t *testing.T) {
+	s := NewServer("")
+	defer s.Close()
+	s.InjectFaults(
+		Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second},
+		Fault{StatusCode: http.StatusServiceUnavailable, Latency: 10 * time.Millisecond},
+	)
+
+	resp, _ := post(t, s, "", "name gauge,1", false)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	start := time.Now()
+	resp, _ = post(t, s, "", "name gauge,1", false)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	resp, _ = post(t, s, "", "name gauge,1", false)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Len(t, s.Metrics(), 1)
+	require.Len(t, s.Requests(), 3)
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+// Enricher provides static dimensions that are added to all data points.
+type Enricher interface {
+	// Enrich returns the current dimensions of the enricher.
+	// If it returns an error, the dimensions of the previous successful call are kept.
+	// The context expires after the Timeout of the exporter options, or after defaultEnrichmentTimeout if none is set.
+	// Calls that do not return in time are treated as failed.
+	Enrich(ctx context.Context) ([]dimensions.Dimension, error)
+	// RefreshInterval is the interval in which Enrich is called again in the background.
+	// If it is zero or negative, Enrich is only called once when the exporter is created.
+	RefreshInterval() time.Duration
+}
+
+// EnricherFunc is an Enricher that calls the function once when the exporter is created.
+type EnricherFunc func(ctx context.Context) ([]dimensions.Dimension, error)
+
+// Enrich calls f.
+func (f EnricherFunc) Enrich(ctx context.Context) ([]dimensions.Dimension, error) {
+	return f(ctx)
+}
+
+// RefreshInterval returns zero, so f is never refreshed.
+func (f EnricherFunc) RefreshInterval() time.Duration {
+	return 0
+}
+
+// defaultEnrichmentTimeout limits the duration of a call to an enrichment source if the options set no timeout.
+const defaultEnrichmentTimeout = 10 * time.Second
+
+// enrichmentSource produces a list of static dimensions.
+// The built-in enrichments and the enrichers from the options are all represented as sources.
+type enrichmentSource struct {
+	name     string
+	enrich   func(ctx context.Context) ([]dimensions.Dimension, error)
+	interval time.Duration
+}
+
+func enricherSource(i int, enricher Enricher) enrichmentSource {
+	return enrichmentSource{
+		name:     fmt.Sprintf("enricher %d (%T)", i, enricher),
+		enrich:   enricher.Enrich,
+		interval: enricher.RefreshInterval(),
+	}
+}
+
+// enrichmentSources returns the sources of static dimensions in order of increasing precedence.
+func enrichmentSources(opts Options) []enrichmentSource {
+	sources := []enrichmentSource{{
+		name: "exporter",
+		enrich: func(context.Context) ([]dimensions.Dimension, error) {
+			return []dimensions.Dimension{dimensions.NewDimension("dt.metrics.source", "opentelemetry")}, nil
+		},
+	}}
+
+	if !opts.DisableDynatraceMetadataEnrichment {
+		sources = append(sources, oneAgentSource(opts.Log))
+	}
+	if opts.EnableKubernetesMetadataEnrichment {
+		sources = append(sources, kubernetesSource("/", os.LookupEnv))
+	}
+	if opts.EnableCloudMetadataEnrichment {
+		source := enricherSource(0, &CloudMetadataEnricher{})
+		source.name = "cloud"
+		sources = append(sources, source)
+	}
+
+	for i, enricher := range opts.Enrichers {
+		sources = append(sources, enricherSource(i, enricher))
+	}
+	return sources
+}
+
+// startEnrichment computes the static dimensions from all sources and starts
+// a background refresh for every source with a refresh interval.
+func (e *Exporter) startEnrichment(sources []enrichmentSource) {
+	e.enriched = make([]dimensionList, len(sources))
+	for i, source := range sources {
+		dims, err := e.enrich(context.Background(), source)
+		if err != nil {
+			e.logger.Warn("could not compute static dimensions", "source", source.name, "error", err)
+			dims = nil
+		}
+		e.enriched[i] = e.newDimensionList(dims...)
+	}
+	e.staticDimensions = mergeDimensionLists(e.enriched...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopEnrichment = cancel
+	for i, source := range sources {
+		if source.interval > 0 {
+			e.enrichmentDone.Add(1)
+			go e.refreshEnrichment(ctx, i, source)
+		}
+	}
+}
+
+// refreshEnrichment periodically calls the source and recomputes the static dimensions if its result changed.
+func (e *Exporter) refreshEnrichment(ctx context.Context, i int, source enrichmentSource) {
+	defer e.enrichmentDone.Done()
+
+	ticker := time.NewTicker(source.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dims, err := e.enrich(ctx, source)
+		if err != nil {
+			if ctx.Err() == nil {
+				e.logger.Warn("could not refresh static dimensions, keeping previous values", "source", source.name, "error", err)
+			}
+			continue
+		}
+
+		list := e.newDimensionList(dims...)
+		e.staticMu.Lock()
+		if formatDimensionList(list.normalized) != formatDimensionList(e.enriched[i].normalized) {
+			e.enriched[i] = list
+			e.staticDimensions = mergeDimensionLists(e.enriched...)
+			e.logger.Debug("static dimensions changed", "source", source.name)
+		}
+		e.staticMu.Unlock()
+	}
+}
+
+// enrich calls the source with a deadline. A source that does not return before the deadline
+// is left running in the background and its result is discarded, so exporter creation never blocks for longer.
+func (e *Exporter) enrich(ctx context.Context, source enrichmentSource) ([]dimensions.Dimension, error) {
+	timeout := e.opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultEnrichmentTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		dims []dimensions.Dimension
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		dims, err := source.enrich(ctx)
+		done <- result{dims, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.dims, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("enrichment did not finish in time: %s", ctx.Err().Error())
+	}
+}
+
+// getStaticDimensions returns the current static dimensions.
+func (e *Exporter) getStaticDimensions() dimensionList {
+	e.staticMu.RLock()
+	defer e.staticMu.RUnlock()
+	return e.staticDimensions
+}
+
+// formatDimensionList returns a string that is equal for lists containing the same dimensions in the same order.
+func formatDimensionList(list dimensions.NormalizedDimensionList) string {
+	return list.Format(func(dims []dimensions.Dimension) string {
+		return fmt.Sprintf("%q", dims)
+	})
+}
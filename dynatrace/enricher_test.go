@@ -0,0 +1,171 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+// changingEnricher returns the next of its results on every call and repeats the last one.
+type changingEnricher struct {
+	mu       sync.Mutex
+	results  [][]dimensions.Dimension
+	errs     []error
+	calls    int
+	interval time.Duration
+}
+
+func (c *changingEnricher) Enrich(context.Context) ([]dimensions.Dimension, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.calls
+	if i >= len(c.results) {
+		i = len(c.results) - 1
+	}
+	c.calls++
+	var err error
+	if i < len(c.errs) {
+		err = c.errs[i]
+	}
+	return c.results[i], err
+}
+
+func (c *changingEnricher) RefreshInterval() time.Duration {
+	return c.interval
+}
+
+func staticDimensionsOf(e *Exporter) string {
+	return e.getStaticDimensions().normalized.Format(func(dims []dimensions.Dimension) string {
+		s := ""
+		for _, d := range dims {
+			s += d.Key + "=" + d.Value + ";"
+		}
+		return s
+	})
+}
+
+func TestExporter_Enrichers(t *testing.T) {
+	t.Run("later enrichers take precedence", func(t *testing.T) {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			Enrichers: []Enricher{
+				EnricherFunc(func(context.Context) ([]dimensions.Dimension, error) {
+					return []dimensions.Dimension{NewDimension("a", "first"), NewDimension("b", "first")}, nil
+				}),
+				EnricherFunc(func(context.Context) ([]dimensions.Dimension, error) {
+					return []dimensions.Dimension{NewDimension("b", "second"), NewDimension("dt.metrics.source", "custom")}, nil
+				}),
+				EnricherFunc(func(context.Context) ([]dimensions.Dimension, error) {
+					return []dimensions.Dimension{NewDimension("c", "ignored")}, errors.New("unavailable")
+				}),
+			},
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		dims := staticDimensionsOf(e)
+		require.Contains(t, dims, "a=first;")
+		require.Contains(t, dims, "b=second;")
+		require.Contains(t, dims, "dt.metrics.source=custom;")
+		require.NotContains(t, dims, "b=first")
+		require.NotContains(t, dims, "c=")
+	})
+
+	t.Run("refreshes in the background", func(t *testing.T) {
+		enricher := &changingEnricher{
+			results: [][]dimensions.Dimension{
+				{NewDimension("host", "one")},
+				{NewDimension("host", "unused")},
+				{NewDimension("host", "two")},
+			},
+			errs:     []error{nil, errors.New("temporarily unavailable")},
+			interval: time.Millisecond,
+		}
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			Enrichers:                          []Enricher{enricher},
+		})
+		require.NoError(t, err)
+		require.Contains(t, staticDimensionsOf(e), "host=one;")
+
+		require.Eventually(t, func() bool {
+			dims := staticDimensionsOf(e)
+			return strings.Contains(dims, "host=two;") && strings.Contains(dims, "dt.metrics.source=opentelemetry;")
+		}, time.Second, time.Millisecond)
+
+		require.NoError(t, e.Close())
+		enricher.mu.Lock()
+		calls := enricher.calls
+		enricher.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		enricher.mu.Lock()
+		defer enricher.mu.Unlock()
+		require.Equal(t, calls, enricher.calls, "refresh must stop when the exporter is closed")
+	})
+}
+
+func TestExporter_Enrichers_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	e, err := NewExporter(Options{
+		DryRun:                             true,
+		DisableDynatraceMetadataEnrichment: true,
+		Timeout:                            20 * time.Millisecond,
+		Enrichers: []Enricher{
+			EnricherFunc(func(context.Context) ([]dimensions.Dimension, error) {
+				<-release
+				return []dimensions.Dimension{NewDimension("late", "true")}, nil
+			}),
+			EnricherFunc(func(ctx context.Context) ([]dimensions.Dimension, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}),
+		},
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, "dt.metrics.source=opentelemetry;", staticDimensionsOf(e))
+}
+
+func Test_enrichmentSources(t *testing.T) {
+	names := func(opts Options) []string {
+		names := []string{}
+		for _, source := range enrichmentSources(opts) {
+			names = append(names, source.name)
+		}
+		return names
+	}
+
+	require.Equal(t, []string{"exporter", "OneAgent"}, names(Options{}))
+	require.Equal(t, []string{"exporter", "Kubernetes", "cloud"}, names(Options{
+		DisableDynatraceMetadataEnrichment: true,
+		EnableKubernetesMetadataEnrichment: true,
+		EnableCloudMetadataEnrichment:      true,
+	}))
+}
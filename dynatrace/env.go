@@ -0,0 +1,231 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"go.uber.org/multierr"
+)
+
+// Environment variables read by OptionsFromEnv.
+const (
+	EnvURL                  = "DT_METRICS_INGEST_URL"
+	EnvAPIToken             = "DT_METRICS_INGEST_API_TOKEN"
+	EnvAPITokenFile         = "DT_METRICS_INGEST_API_TOKEN_FILE"
+	EnvPrefix               = "DT_METRICS_PREFIX"
+	EnvDefaultDimensions    = "DT_METRICS_DEFAULT_DIMENSIONS"
+	EnvDisableEnrichment    = "DT_METRICS_DISABLE_METADATA_ENRICHMENT"
+	EnvKubernetesEnrichment = "DT_METRICS_ENABLE_KUBERNETES_ENRICHMENT"
+	EnvCloudEnrichment      = "DT_METRICS_ENABLE_CLOUD_ENRICHMENT"
+	EnvCompression          = "DT_METRICS_COMPRESSION"
+	EnvTimeout              = "DT_METRICS_TIMEOUT"
+)
+
+// NewExporterFromEnv creates an exporter configured from the environment variables
+// read by OptionsFromEnv. Options that are set explicitly in opts take precedence
+// over values read from the environment.
+//
+// An option in opts counts as set if it differs from its zero value. To reset an option from the environment
+// to its zero value, for example to turn off gzip compression, pass an override. Overrides are applied
+// in order to the merged options, so they take precedence over both opts and the environment.
+//
+// The API token from the environment is only used if the URL is also taken from the environment,
+// so that the token is not sent to an endpoint set explicitly in opts.
+func NewExporterFromEnv(opts Options, overrides ...func(*Options)) (*Exporter, error) {
+	envOpts, err := OptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeOptions(envOpts, opts)
+	for _, override := range overrides {
+		override(&merged)
+	}
+	return NewExporter(merged)
+}
+
+// OptionsFromEnv reads exporter options from well-known environment variables.
+// Variables that are not set leave the corresponding option at its zero value.
+// All invalid variables are reported together in the returned error.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{}
+	var errs error
+
+	if val, ok := lookupEnv(EnvURL); ok {
+		if _, err := url.ParseRequestURI(val); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: invalid URL %q: %s", EnvURL, val, err.Error()))
+		} else {
+			opts.URL = val
+		}
+	}
+
+	token, hasToken := lookupEnv(EnvAPIToken)
+	tokenFile, hasTokenFile := lookupEnv(EnvAPITokenFile)
+	switch {
+	case hasToken && hasTokenFile:
+		errs = multierr.Append(errs, fmt.Errorf("%s and %s are mutually exclusive", EnvAPIToken, EnvAPITokenFile))
+	case hasToken:
+		opts.APIToken = token
+	case hasTokenFile:
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: cannot read token file: %s", EnvAPITokenFile, err.Error()))
+		} else if opts.APIToken = strings.TrimSpace(string(content)); opts.APIToken == "" {
+			errs = multierr.Append(errs, fmt.Errorf("%s: token file %q is empty", EnvAPITokenFile, tokenFile))
+		}
+	}
+
+	if val, ok := lookupEnv(EnvPrefix); ok {
+		opts.Prefix = val
+	}
+
+	if val, ok := lookupEnv(EnvDefaultDimensions); ok {
+		dims, err := parseDimensionList(val)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: %s", EnvDefaultDimensions, err.Error()))
+		} else {
+			opts.DefaultDimensions = dims
+		}
+	}
+
+	if val, ok := lookupEnv(EnvDisableEnrichment); ok {
+		disable, err := strconv.ParseBool(val)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: invalid boolean %q", EnvDisableEnrichment, val))
+		} else {
+			opts.DisableDynatraceMetadataEnrichment = disable
+		}
+	}
+
+	if val, ok := lookupEnv(EnvKubernetesEnrichment); ok {
+		enable, err := strconv.ParseBool(val)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: invalid boolean %q", EnvKubernetesEnrichment, val))
+		} else {
+			opts.EnableKubernetesMetadataEnrichment = enable
+		}
+	}
+
+	if val, ok := lookupEnv(EnvCloudEnrichment); ok {
+		enable, err := strconv.ParseBool(val)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: invalid boolean %q", EnvCloudEnrichment, val))
+		} else {
+			opts.EnableCloudMetadataEnrichment = enable
+		}
+	}
+
+	if val, ok := lookupEnv(EnvCompression); ok {
+		compression, err := parseCompression(val)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: %s", EnvCompression, err.Error()))
+		} else {
+			opts.Compression = compression
+		}
+	}
+
+	if val, ok := lookupEnv(EnvTimeout); ok {
+		timeout, err := time.ParseDuration(val)
+		if err != nil || timeout < 0 {
+			errs = multierr.Append(errs, fmt.Errorf("%s: invalid duration %q", EnvTimeout, val))
+		} else {
+			opts.Timeout = timeout
+		}
+	}
+
+	return opts, errs
+}
+
+// lookupEnv returns the trimmed value of the environment variable and
+// treats variables that are set to an empty string as unset.
+func lookupEnv(name string) (string, bool) {
+	val, ok := os.LookupEnv(name)
+	val = strings.TrimSpace(val)
+	return val, ok && val != ""
+}
+
+// parseDimensionList parses a list in the form "k=v,k2=v2". Empty entries are ignored.
+func parseDimensionList(s string) ([]dimensions.Dimension, error) {
+	dims := []dimensions.Dimension{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid dimension %q, expected key=value", entry)
+		}
+		dims = append(dims, NewDimension(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])))
+	}
+	return dims, nil
+}
+
+func parseCompression(s string) (Compression, error) {
+	switch strings.ToLower(s) {
+	case "none":
+		return NoCompression, nil
+	case "gzip":
+		return GzipCompression, nil
+	default:
+		return NoCompression, fmt.Errorf("unsupported compression %q, expected none or gzip", s)
+	}
+}
+
+// mergeOptions returns opts with every option that is not set explicitly filled in from env.
+// Default dimensions from env are kept, but are overridden by explicit dimensions with the same key.
+// Zero values in opts count as not set, so booleans from env can only be turned on and
+// compression from env can only be replaced by another compression.
+// The API token from env is only used for the URL from env.
+func mergeOptions(env, opts Options) Options {
+	if opts.APIToken == "" && (opts.URL == "" || opts.URL == env.URL) {
+		opts.APIToken = env.APIToken
+	}
+	if opts.URL == "" {
+		opts.URL = env.URL
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = env.Prefix
+	}
+	if len(env.DefaultDimensions) > 0 {
+		opts.DefaultDimensions = append(append([]dimensions.Dimension{}, env.DefaultDimensions...), opts.DefaultDimensions...)
+	}
+	if env.DisableDynatraceMetadataEnrichment {
+		opts.DisableDynatraceMetadataEnrichment = true
+	}
+	if env.EnableKubernetesMetadataEnrichment {
+		opts.EnableKubernetesMetadataEnrichment = true
+	}
+	if env.EnableCloudMetadataEnrichment {
+		opts.EnableCloudMetadataEnrichment = true
+	}
+	if opts.Compression == NoCompression {
+		opts.Compression = env.Compression
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = env.Timeout
+	}
+
+	return opts
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Run("reads all variables", func(t *testing.T) {
+		t.Setenv(EnvURL, "https://example.com/api/v2/metrics/ingest")
+		t.Setenv(EnvAPIToken, "token")
+		t.Setenv(EnvPrefix, "prefix")
+		t.Setenv(EnvDefaultDimensions, "k=v, k2 = v2 ,")
+		t.Setenv(EnvDisableEnrichment, "true")
+		t.Setenv(EnvKubernetesEnrichment, "1")
+		t.Setenv(EnvCloudEnrichment, "true")
+		t.Setenv(EnvCompression, "GZIP")
+		t.Setenv(EnvTimeout, "5s")
+
+		opts, err := OptionsFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/api/v2/metrics/ingest", opts.URL)
+		require.Equal(t, "token", opts.APIToken)
+		require.Equal(t, "prefix", opts.Prefix)
+		require.Equal(t, []dimensions.Dimension{NewDimension("k", "v"), NewDimension("k2", "v2")}, opts.DefaultDimensions)
+		require.True(t, opts.DisableDynatraceMetadataEnrichment)
+		require.True(t, opts.EnableKubernetesMetadataEnrichment)
+		require.True(t, opts.EnableCloudMetadataEnrichment)
+		require.Equal(t, GzipCompression, opts.Compression)
+		require.Equal(t, 5*time.Second, opts.Timeout)
+	})
+
+	t.Run("reads token from file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, ioutil.WriteFile(file, []byte("filetoken\n"), 0600))
+		t.Setenv(EnvAPITokenFile, file)
+
+		opts, err := OptionsFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, "filetoken", opts.APIToken)
+	})
+
+	t.Run("reports all invalid variables", func(t *testing.T) {
+		t.Setenv(EnvURL, "not a url")
+		t.Setenv(EnvAPIToken, "token")
+		t.Setenv(EnvAPITokenFile, "/some/file")
+		t.Setenv(EnvDefaultDimensions, "novalue")
+		t.Setenv(EnvDisableEnrichment, "maybe")
+		t.Setenv(EnvCompression, "zstd")
+		t.Setenv(EnvTimeout, "soon")
+
+		_, err := OptionsFromEnv()
+		require.Error(t, err)
+		for _, name := range []string{EnvURL, EnvAPITokenFile, EnvDefaultDimensions, EnvDisableEnrichment, EnvCompression, EnvTimeout} {
+			require.Contains(t, err.Error(), name)
+		}
+	})
+}
+
+func TestNewExporterFromEnv(t *testing.T) {
+	t.Setenv(EnvURL, "https://env.example.com")
+	t.Setenv(EnvAPIToken, "envtoken")
+	t.Setenv(EnvPrefix, "envprefix")
+	t.Setenv(EnvDefaultDimensions, "from=env,env=true")
+	t.Setenv(EnvDisableEnrichment, "true")
+
+	e, err := NewExporterFromEnv(Options{
+		URL:               "https://explicit.example.com",
+		APIToken:          "explicittoken",
+		DefaultDimensions: []dimensions.Dimension{NewDimension("from", "explicit")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "https://explicit.example.com", e.opts.URL)
+	require.Equal(t, "explicittoken", e.opts.APIToken)
+	require.Equal(t, "envprefix", e.opts.Prefix)
+	require.Equal(t, []dimensions.Dimension{
+		NewDimension("from", "env"),
+		NewDimension("env", "true"),
+		NewDimension("from", "explicit"),
+	}, e.opts.DefaultDimensions)
+}
+
+func TestNewExporterFromEnv_ZeroValues(t *testing.T) {
+	t.Setenv(EnvURL, "http://localhost:14499/metrics/ingest")
+	t.Setenv(EnvDisableEnrichment, "true")
+	t.Setenv(EnvCompression, "gzip")
+
+	t.Run("explicit zero values do not override the environment", func(t *testing.T) {
+		e, err := NewExporterFromEnv(Options{
+			DisableDynatraceMetadataEnrichment: false,
+			Compression:                        NoCompression,
+		})
+		require.NoError(t, err)
+		require.True(t, e.opts.DisableDynatraceMetadataEnrichment)
+		require.Equal(t, GzipCompression, e.opts.Compression)
+	})
+
+	t.Run("overrides reset options from the environment", func(t *testing.T) {
+		e, err := NewExporterFromEnv(Options{}, func(opts *Options) {
+			opts.DisableDynatraceMetadataEnrichment = false
+			opts.Compression = NoCompression
+		})
+		require.NoError(t, err)
+		require.False(t, e.opts.DisableDynatraceMetadataEnrichment)
+		require.Equal(t, NoCompression, e.opts.Compression)
+		require.Equal(t, "http://localhost:14499/metrics/ingest", e.opts.URL)
+	})
+
+	t.Run("options from the environment can be reset before creating the exporter", func(t *testing.T) {
+		opts, err := OptionsFromEnv()
+		require.NoError(t, err)
+		opts.DisableDynatraceMetadataEnrichment = false
+		opts.Compression = NoCompression
+
+		e, err := NewExporter(opts)
+		require.NoError(t, err)
+		require.False(t, e.opts.DisableDynatraceMetadataEnrichment)
+		require.Equal(t, NoCompression, e.opts.Compression)
+	})
+}
+
+func TestNewExporterFromEnv_APIToken(t *testing.T) {
+	t.Setenv(EnvURL, "https://env.example.com")
+	t.Setenv(EnvAPIToken, "envtoken")
+	t.Setenv(EnvDisableEnrichment, "true")
+
+	t.Run("the token is used for the URL from the environment", func(t *testing.T) {
+		for _, opts := range []Options{{}, {URL: "https://env.example.com"}} {
+			e, err := NewExporterFromEnv(opts)
+			require.NoError(t, err)
+			require.Equal(t, "envtoken", e.opts.APIToken)
+		}
+	})
+
+	t.Run("the token is not sent to another endpoint", func(t *testing.T) {
+		_, err := NewExporterFromEnv(Options{URL: "https://other.example.com"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "an API token is required")
+
+		e, err := NewExporterFromEnv(Options{URL: "https://other.example.com", APIToken: "token"})
+		require.NoError(t, err)
+		require.Equal(t, "token", e.opts.APIToken)
+	})
+}
@@ -0,0 +1,235 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/normalize"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.uber.org/multierr"
+)
+
+// FilterAction decides what happens to a metric after a matching FilterRule has been applied.
+type FilterAction int
+
+const (
+	// FilterContinue applies the transformations of the rule and continues with the next rule.
+	FilterContinue FilterAction = iota
+	// FilterKeep applies the transformations of the rule and exports the metric without evaluating further rules.
+	FilterKeep
+	// FilterDrop drops the metric.
+	FilterDrop
+)
+
+// FilterRule matches metrics and drops, keeps or transforms them before they are serialized.
+// A rule matches a metric if all of its non-empty conditions match.
+type FilterRule struct {
+	// Name is a glob pattern for the metric name, where * matches any sequence of characters and ? a single character.
+	Name string
+	// NameRegexp is a regular expression the metric name must match.
+	NameRegexp string
+	// Scope is a glob pattern for the name of the instrumentation scope.
+	Scope string
+	// InstrumentKinds lists the instrument kinds the rule applies to.
+	InstrumentKinds []sdkapi.InstrumentKind
+	// Dimensions maps attribute keys to glob patterns their values must match.
+	// Only the string attributes of the data point are considered.
+	Dimensions map[string]string
+
+	// Action is applied after the transformations below.
+	Action FilterAction
+	// Rename replaces the metric name.
+	Rename string
+	// DropDimensions removes dimensions from the metric, regardless of where they come from.
+	DropDimensions []string
+	// AddDimensions adds dimensions to the metric, which take precedence over all other dimensions.
+	AddDimensions []dimensions.Dimension
+}
+
+type compiledFilterRule struct {
+	rule       FilterRule
+	name       *regexp.Regexp
+	nameRegexp *regexp.Regexp
+	scope      *regexp.Regexp
+	dimensions map[string]*regexp.Regexp
+	dropKeys   map[string]bool
+}
+
+// filterResult is the combined outcome of all rules matching a metric.
+type filterResult struct {
+	name     string
+	drop     bool
+	dropKeys map[string]bool
+	add      dimensionList
+}
+
+// compileFilterRules compiles the patterns of all rules. All invalid patterns are reported together.
+func compileFilterRules(rules []FilterRule) ([]compiledFilterRule, error) {
+	compiled := make([]compiledFilterRule, 0, len(rules))
+	var errs error
+
+	for i, rule := range rules {
+		c := compiledFilterRule{rule: rule, dimensions: map[string]*regexp.Regexp{}, dropKeys: map[string]bool{}}
+		var err error
+
+		if rule.Name != "" {
+			c.name = compileGlob(rule.Name)
+		}
+		if rule.NameRegexp != "" {
+			if c.nameRegexp, err = regexp.Compile(rule.NameRegexp); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("filter rule %d: invalid name regexp: %s", i, err.Error()))
+			}
+		}
+		if rule.Scope != "" {
+			c.scope = compileGlob(rule.Scope)
+		}
+		for key, pattern := range rule.Dimensions {
+			c.dimensions[key] = compileGlob(pattern)
+		}
+		for _, key := range rule.DropDimensions {
+			normalized, err := normalize.DimensionKey(key)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("filter rule %d: invalid dimension key %q: %s", i, key, err.Error()))
+				continue
+			}
+			c.dropKeys[normalized] = true
+		}
+		if rule.Action < FilterContinue || rule.Action > FilterDrop {
+			errs = multierr.Append(errs, fmt.Errorf("filter rule %d: unknown action %d", i, rule.Action))
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, errs
+}
+
+// compileGlob converts a glob pattern with * and ? wildcards to an anchored regular expression.
+func compileGlob(pattern string) *regexp.Regexp {
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+func (c *compiledFilterRule) matches(name, scope string, kind sdkapi.InstrumentKind, attrs map[string]string) bool {
+	if c.name != nil && !c.name.MatchString(name) {
+		return false
+	}
+	if c.nameRegexp != nil && !c.nameRegexp.MatchString(name) {
+		return false
+	}
+	if c.scope != nil && !c.scope.MatchString(scope) {
+		return false
+	}
+	if len(c.rule.InstrumentKinds) > 0 {
+		found := false
+		for _, k := range c.rule.InstrumentKinds {
+			found = found || k == kind
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, pattern := range c.dimensions {
+		val, ok := attrs[key]
+		if !ok || !pattern.MatchString(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFilters evaluates the filter rules in order for a single metric.
+// Rules are matched against the name after the transformations of earlier rules.
+func (e *Exporter) applyFilters(name, scope string, kind sdkapi.InstrumentKind, attrs []dimensions.Dimension) (result filterResult) {
+	result.name = name
+	if len(e.filters) == 0 {
+		return result
+	}
+
+	add := []dimensions.Dimension{}
+	defer func() {
+		if len(add) > 0 && !result.drop {
+			result.add = e.newDimensionList(add...)
+		}
+	}()
+
+	attrMap := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		attrMap[attr.Key] = attr.Value
+	}
+
+	for i := range e.filters {
+		f := &e.filters[i]
+		if !f.matches(result.name, scope, kind, attrMap) {
+			continue
+		}
+		if f.rule.Action == FilterDrop {
+			result.drop = true
+			return result
+		}
+
+		if f.rule.Rename != "" {
+			result.name = f.rule.Rename
+		}
+		for key := range f.dropKeys {
+			if result.dropKeys == nil {
+				result.dropKeys = map[string]bool{}
+			}
+			result.dropKeys[key] = true
+		}
+		add = append(add, f.rule.AddDimensions...)
+
+		if f.rule.Action == FilterKeep {
+			return result
+		}
+	}
+	return result
+}
+
+// apply merges the lists, then removes and adds the dimensions requested by the matching rules.
+// Added dimensions are subject to the same anonymization and limits as all others.
+// Dimensions are removed before normalization, so the remaining values are escaped only once.
+func (r filterResult) apply(lists ...dimensionList) dimensions.NormalizedDimensionList {
+	var list dimensions.NormalizedDimensionList
+	if len(r.dropKeys) > 0 {
+		list = mergeDimensionLists(lists...).without(r.dropKeys)
+	} else {
+		normalized := make([]dimensions.NormalizedDimensionList, 0, len(lists))
+		for _, l := range lists {
+			normalized = append(normalized, l.normalized)
+		}
+		list = dimensions.MergeLists(normalized...)
+	}
+	if len(r.add.source) > 0 {
+		list = dimensions.MergeLists(list, r.add.normalized)
+	}
+	return list
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// gaugeRecord returns a record of a float up-down counter, which is exported as a gauge.
+func gaugeRecord(t *testing.T, name string, value float64, attrs ...attribute.KeyValue) export.Record {
+	t.Helper()
+	desc := metrictest.NewDescriptor(name, sdkapi.UpDownCounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(value), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+	set := attribute.NewSet(attrs...)
+	return export.NewRecord(&desc, &set, ckpt.Aggregation(), intervalStart, intervalEnd)
+}
+
+func TestExporter_Export_FilterRules(t *testing.T) {
+	records := func() map[instrumentation.Library][]export.Record {
+		return map[instrumentation.Library][]export.Record{
+			{Name: "github.com/example/http"}: {
+				counterRecord(t, "http.requests", 1, attribute.String("route", "/api/users"), attribute.String("user.id", "42")),
+				counterRecord(t, "http.requests", 2, attribute.String("route", "/health")),
+				gaugeRecord(t, "http.connections", 3),
+			},
+			{Name: "runtime"}: {
+				gaugeRecord(t, "runtime.go.goroutines", 4),
+				counterRecord(t, "runtime.go.gc.count", 5),
+			},
+		}
+	}
+
+	t.Run("drop by scope and dimension value", func(t *testing.T) {
+		lines := exportLines(t, Options{FilterRules: []FilterRule{
+			{Scope: "runtime", Action: FilterDrop},
+			{Name: "http.*", Dimensions: map[string]string{"route": "/health"}, Action: FilterDrop},
+		}}, resource.Empty(), records())
+		require.ElementsMatch(t, []string{
+			"http.requests,dt.metrics.source=opentelemetry,route=/api/users,user.id=42 count,delta=1 " + intervalEndMillis,
+			"http.connections,dt.metrics.source=opentelemetry gauge,3 " + intervalEndMillis,
+		}, sortDimensionsOfLines(lines))
+	})
+
+	t.Run("keep allowlist", func(t *testing.T) {
+		lines := exportLines(t, Options{FilterRules: []FilterRule{
+			{NameRegexp: `^runtime\.go\.(goroutines|gc\..*)$`, InstrumentKinds: []sdkapi.InstrumentKind{sdkapi.UpDownCounterInstrumentKind}, Action: FilterKeep},
+			{Name: "*", Action: FilterDrop},
+		}}, resource.Empty(), records())
+		require.Equal(t, []string{"runtime.go.goroutines,dt.metrics.source=opentelemetry gauge,4 " + intervalEndMillis}, lines)
+	})
+
+	t.Run("rename and change dimensions", func(t *testing.T) {
+		lines := exportLines(t, Options{
+			DefaultDimensions: []dimensions.Dimension{NewDimension("env", "prod")},
+			FilterRules: []FilterRule{
+				{Name: "http.requests", Rename: "web.requests", DropDimensions: []string{"user.id", "env"}},
+				{Name: "web.requests", AddDimensions: []dimensions.Dimension{NewDimension("team", "web")}},
+				{Name: "http.requests", Action: FilterDrop},
+				{Scope: "runtime", Action: FilterDrop},
+				{Name: "http.connections", Action: FilterDrop},
+			},
+		}, resource.Empty(), records())
+		require.ElementsMatch(t, []string{
+			"web.requests,dt.metrics.source=opentelemetry,route=/api/users,team=web count,delta=1 " + intervalEndMillis,
+			"web.requests,dt.metrics.source=opentelemetry,route=/health,team=web count,delta=2 " + intervalEndMillis,
+		}, sortDimensionsOfLines(lines))
+	})
+
+	t.Run("dropping dimensions keeps the others escaped once", func(t *testing.T) {
+		withEscapes := func() map[instrumentation.Library][]export.Record {
+			return map[instrumentation.Library][]export.Record{
+				{Name: "lib"}: {gaugeRecord(t, "gauge", 1, attribute.String("path", `a b"c\d`), attribute.String("drop", "x"))},
+			}
+		}
+		opts := Options{
+			DefaultDimensions: []dimensions.Dimension{NewDimension("env", "prod, eu"), NewDimension("long", strings.Repeat("x", 30))},
+			DimensionLimits:   DimensionLimits{MaxValueLength: 20},
+		}
+		unfiltered := exportLines(t, opts, resource.Empty(), withEscapes())
+		require.Len(t, unfiltered, 1)
+
+		opts.FilterRules = []FilterRule{{Name: "gauge", DropDimensions: []string{"drop"}}}
+		lines := exportLines(t, opts, resource.Empty(), withEscapes())
+		require.Len(t, lines, 1)
+		for _, dim := range []string{`path=a\ b\"c\\d`, `env=prod\,\ eu`, "long=" + strings.Repeat("x", 20), "dt.metrics.source=opentelemetry"} {
+			require.Contains(t, unfiltered[0], dim)
+			require.Contains(t, lines[0], dim)
+		}
+		require.NotContains(t, lines[0], "drop=")
+	})
+}
+
+func TestValidateOptions_FilterRules(t *testing.T) {
+	_, err := NewExporter(Options{DryRun: true, FilterRules: []FilterRule{
+		{NameRegexp: "("},
+		{DropDimensions: []string{""}},
+		{Action: FilterAction(7)},
+	}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "filter rule 0: invalid name regexp")
+	require.Contains(t, err.Error(), `filter rule 1: invalid dimension key ""`)
+	require.Contains(t, err.Error(), "filter rule 2: unknown action 7")
+}
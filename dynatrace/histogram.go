@@ -1,12 +1,11 @@
 package dynatrace
 
 import (
-	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
 	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
 	"go.opentelemetry.io/otel/sdk/metric/number"
 )
 
-func summaryFromHistogram(hist aggregation.Histogram, kind number.Kind) (metric.MetricOption, error) {
+func summaryFromHistogram(hist aggregation.Histogram, kind number.Kind) (*summary, error) {
 	// export histogram
 	sum, err := hist.Sum()
 	if err != nil {
@@ -25,7 +24,22 @@ func summaryFromHistogram(hist aggregation.Histogram, kind number.Kind) (metric.
 
 	min, max := estimateHistMinMax(buckets.Boundaries, buckets.Counts)
 
-	return metric.WithFloatSummaryValue(min, max, sum.CoerceToFloat64(kind), int64(count)), nil
+	// The estimated min and max are bucket boundaries, so integers are only kept if the boundaries are whole numbers.
+	if kind == number.Int64Kind {
+		intMin, minOk := integerNumber(min)
+		intMax, maxOk := integerNumber(max)
+		if minOk && maxOk {
+			return &summary{min: intMin, max: intMax, sum: sum, count: count, kind: kind}, nil
+		}
+	}
+
+	return &summary{
+		min:   number.NewFloat64Number(min),
+		max:   number.NewFloat64Number(max),
+		sum:   number.NewFloat64Number(sum.CoerceToFloat64(kind)),
+		count: count,
+		kind:  number.Float64Kind,
+	}, nil
 }
 
 // estimateHistMinMax returns the estimated minimum and maximum value in the histogram by using the min and max non-empty buckets.
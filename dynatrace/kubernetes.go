@@ -0,0 +1,159 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+// Dimension keys set by the Kubernetes enrichment.
+const (
+	k8sNamespaceName = "k8s.namespace.name"
+	k8sPodName       = "k8s.pod.name"
+	k8sNodeName      = "k8s.node.name"
+	k8sWorkloadName  = "k8s.workload.name"
+	containerID      = "container.id"
+)
+
+const (
+	// kubernetesPodInfoDir is where the downward API volume is expected to be mounted.
+	kubernetesPodInfoDir = "/etc/podinfo"
+	// kubernetesServiceAccountNamespace is mounted into every pod that has a service account token.
+	kubernetesServiceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	procSelfCgroup                    = "/proc/self/cgroup"
+)
+
+// kubernetesEnvVars lists the environment variables per dimension key in order of preference.
+// They are expected to be set from the downward API in the pod spec.
+var kubernetesEnvVars = map[string][]string{
+	k8sNamespaceName: {"K8S_NAMESPACE_NAME", "POD_NAMESPACE"},
+	k8sPodName:       {"K8S_POD_NAME", "POD_NAME"},
+	k8sNodeName:      {"K8S_NODE_NAME", "NODE_NAME"},
+	k8sWorkloadName:  {"K8S_WORKLOAD_NAME"},
+	containerID:      {"CONTAINER_ID"},
+}
+
+// kubernetesPodInfoFiles maps dimension keys to the files of the downward API volume.
+var kubernetesPodInfoFiles = map[string]string{
+	k8sNamespaceName: "namespace",
+	k8sPodName:       "name",
+	k8sNodeName:      "nodename",
+}
+
+// kubernetesWorkloadLabels are the pod labels that name the workload, in order of preference.
+var kubernetesWorkloadLabels = []string{"app.kubernetes.io/name", "app"}
+
+var reContainerID = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// kubernetesSource returns the enrichment source of the Kubernetes metadata of the current pod.
+// The metadata does not change during the lifetime of a pod, so each exporter reads it once when it is created.
+func kubernetesSource(root string, lookupEnv func(string) (string, bool)) enrichmentSource {
+	return enrichmentSource{
+		name: "Kubernetes",
+		enrich: func(context.Context) ([]dimensions.Dimension, error) {
+			return readKubernetesMetadata(root, lookupEnv), nil
+		},
+	}
+}
+
+// readKubernetesMetadata collects the Kubernetes dimensions of the current pod.
+// Environment variables take precedence over the downward API volume, which takes precedence
+// over the service account namespace. The container ID is read from /proc/self/cgroup if not set.
+// root is "/" outside of tests. Dimensions that cannot be determined are omitted.
+func readKubernetesMetadata(root string, lookupEnv func(string) (string, bool)) []dimensions.Dimension {
+	values := map[string]string{}
+
+	if ns := readTrimmedFile(filepath.Join(root, kubernetesServiceAccountNamespace)); ns != "" {
+		values[k8sNamespaceName] = ns
+	}
+
+	podInfo := filepath.Join(root, kubernetesPodInfoDir)
+	for key, file := range kubernetesPodInfoFiles {
+		if val := readTrimmedFile(filepath.Join(podInfo, file)); val != "" {
+			values[key] = val
+		}
+	}
+	labels := parsePodInfoLabels(readTrimmedFile(filepath.Join(podInfo, "labels")))
+	for _, label := range kubernetesWorkloadLabels {
+		if val := labels[label]; val != "" {
+			values[k8sWorkloadName] = val
+			break
+		}
+	}
+
+	if id := containerIDFromCgroup(readTrimmedFile(filepath.Join(root, procSelfCgroup))); id != "" {
+		values[containerID] = id
+	}
+
+	for key, names := range kubernetesEnvVars {
+		for _, name := range names {
+			if val, ok := lookupEnv(name); ok && strings.TrimSpace(val) != "" {
+				values[key] = strings.TrimSpace(val)
+				break
+			}
+		}
+	}
+
+	dims := []dimensions.Dimension{}
+	for _, key := range []string{k8sNamespaceName, k8sPodName, k8sNodeName, k8sWorkloadName, containerID} {
+		if val, ok := values[key]; ok {
+			dims = append(dims, dimensions.NewDimension(key, val))
+		}
+	}
+	return dims
+}
+
+func readTrimmedFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// parsePodInfoLabels parses the labels file of the downward API volume, which contains one key="value" pair per line.
+func parsePodInfoLabels(content string) map[string]string {
+	labels := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if val, err := strconv.Unquote(kv[1]); err == nil {
+			labels[kv[0]] = val
+		}
+	}
+	return labels
+}
+
+// containerIDFromCgroup extracts the 64 character container ID from the cgroup paths of the current process.
+func containerIDFromCgroup(content string) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if id := reContainerID.FindString(scanner.Text()); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+const testContainerID = "2d3a2f0b6f0e4c9f8a3b1c5d7e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f"
+
+func writeRootFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+}
+
+func Test_readKubernetesMetadata(t *testing.T) {
+	t.Run("reads downward API files and cgroup", func(t *testing.T) {
+		root := t.TempDir()
+		writeRootFile(t, root, kubernetesServiceAccountNamespace, "from-service-account")
+		writeRootFile(t, root, filepath.Join(kubernetesPodInfoDir, "namespace"), "shop\n")
+		writeRootFile(t, root, filepath.Join(kubernetesPodInfoDir, "name"), "checkout-7d9f8b6c5-x2k4p")
+		writeRootFile(t, root, filepath.Join(kubernetesPodInfoDir, "labels"), "app=\"fallback\"\napp.kubernetes.io/name=\"checkout\"\npod-template-hash=\"7d9f8b6c5\"\n")
+		writeRootFile(t, root, procSelfCgroup, "12:memory:/kubepods/burstable/pod1234/"+testContainerID+"\n0::/\n")
+
+		dims := readKubernetesMetadata(root, envMap(nil))
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension(k8sNamespaceName, "shop"),
+			NewDimension(k8sPodName, "checkout-7d9f8b6c5-x2k4p"),
+			NewDimension(k8sWorkloadName, "checkout"),
+			NewDimension(containerID, testContainerID),
+		}, dims)
+	})
+
+	t.Run("environment takes precedence", func(t *testing.T) {
+		root := t.TempDir()
+		writeRootFile(t, root, filepath.Join(kubernetesPodInfoDir, "namespace"), "from-file")
+		writeRootFile(t, root, kubernetesServiceAccountNamespace, "from-service-account")
+
+		dims := readKubernetesMetadata(root, envMap(map[string]string{
+			"POD_NAMESPACE":     "from-env",
+			"K8S_POD_NAME":      "pod",
+			"POD_NAME":          "ignored",
+			"NODE_NAME":         "node-1",
+			"K8S_WORKLOAD_NAME": " ",
+		}))
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension(k8sNamespaceName, "from-env"),
+			NewDimension(k8sPodName, "pod"),
+			NewDimension(k8sNodeName, "node-1"),
+		}, dims)
+	})
+
+	t.Run("nothing outside of Kubernetes", func(t *testing.T) {
+		require.Empty(t, readKubernetesMetadata(t.TempDir(), envMap(nil)))
+	})
+}
+
+func Test_containerIDFromCgroup(t *testing.T) {
+	require.Equal(t, testContainerID, containerIDFromCgroup("0::/system.slice/docker-"+testContainerID+".scope"))
+	require.Equal(t, testContainerID, containerIDFromCgroup("1:name=systemd:/\n2:cpu:/kubepods/besteffort/pod-abc/cri-containerd-"+testContainerID+".scope"))
+	require.Empty(t, containerIDFromCgroup("0::/user.slice/user-1000.slice"))
+}
+
+func Test_kubernetesSource(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeRootFile(t, first, filepath.Join(kubernetesPodInfoDir, "name"), "first")
+	writeRootFile(t, second, filepath.Join(kubernetesPodInfoDir, "name"), "second")
+
+	for root, pod := range map[string]string{first: "first", second: "second"} {
+		source := kubernetesSource(root, envMap(nil))
+		require.Zero(t, source.interval)
+
+		dims, err := source.enrich(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []dimensions.Dimension{NewDimension(k8sPodName, pod)}, dims)
+	}
+}
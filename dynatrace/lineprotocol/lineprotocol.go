@@ -0,0 +1,537 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lineprotocol parses text in the Dynatrace metrics ingestion protocol
+// into structured metric and metadata lines.
+package lineprotocol
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the ingest API enforced by the parser.
+const (
+	MaxLineLength           = 50000
+	MaxMetricKeyLength      = 250
+	MaxDimensionKeyLength   = 100
+	MaxDimensionValueLength = 250
+)
+
+var (
+	reMetricKey    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*(\.[a-zA-Z0-9_][a-zA-Z0-9_-]*)*$`)
+	reDimensionKey = regexp.MustCompile(`^[a-z_][a-z0-9_:-]*(\.[a-z0-9_:-]+)*$`)
+)
+
+// PayloadType is the kind of value carried by a line.
+type PayloadType int
+
+const (
+	// Gauge is a single gauge value, e.g. "gauge,5".
+	Gauge PayloadType = iota + 1
+	// CountDelta is a counter delta, e.g. "count,delta=5".
+	CountDelta
+	// Summary is a gauge summary, e.g. "gauge,min=1,max=5,sum=8,count=3".
+	Summary
+)
+
+func (t PayloadType) String() string {
+	switch t {
+	case Gauge:
+		return "gauge"
+	case CountDelta:
+		return "count"
+	case Summary:
+		return "summary"
+	default:
+		return fmt.Sprintf("PayloadType(%d)", int(t))
+	}
+}
+
+// Number is a numeric value of a payload. Integers are kept as int64 so that no precision is lost.
+type Number struct {
+	IsInt bool
+	Int   int64
+	Float float64
+}
+
+// Float64 returns the number as float64, converting integers if necessary.
+func (n Number) Float64() float64 {
+	if n.IsInt {
+		return float64(n.Int)
+	}
+	return n.Float
+}
+
+func (n Number) String() string {
+	if n.IsInt {
+		return strconv.FormatInt(n.Int, 10)
+	}
+	formatted := strconv.FormatFloat(n.Float, 'g', -1, 64)
+	if i := strings.Index(formatted, "e"); i >= 0 && !strings.Contains(formatted, ".") {
+		// the line protocol requires a decimal point in scientific notation
+		formatted = formatted[:i] + ".0" + formatted[i:]
+	}
+	return formatted
+}
+
+// Payload is the value part of a metric line. Value is set for Gauge and CountDelta,
+// Min, Max, Sum and Count are set for Summary.
+type Payload struct {
+	Type  PayloadType
+	Value Number
+	Min   Number
+	Max   Number
+	Sum   Number
+	Count int64
+}
+
+func (p Payload) String() string {
+	switch p.Type {
+	case Gauge:
+		return "gauge," + p.Value.String()
+	case CountDelta:
+		return "count,delta=" + p.Value.String()
+	case Summary:
+		return fmt.Sprintf("gauge,min=%s,max=%s,sum=%s,count=%d", p.Min, p.Max, p.Sum, p.Count)
+	default:
+		return ""
+	}
+}
+
+// Dimension is a dimension of a metric line with its value unescaped.
+type Dimension struct {
+	Key   string
+	Value string
+}
+
+// Line is a parsed metric line.
+type Line struct {
+	Key        string
+	Dimensions []Dimension
+	Payload    Payload
+	// Timestamp is the zero time if the line has no timestamp.
+	Timestamp time.Time
+}
+
+// Dimension returns the value of the dimension with the given key.
+// If the key occurs more than once, the last value wins, as it does on ingest.
+func (l Line) Dimension(key string) (string, bool) {
+	value, found := "", false
+	for _, dim := range l.Dimensions {
+		if dim.Key == key {
+			value, found = dim.Value, true
+		}
+	}
+	return value, found
+}
+
+// Metadata is a parsed metadata line, e.g. `#my.metric gauge dt.meta.unit=Byte`.
+type Metadata struct {
+	Key string
+	// Type is either Gauge or CountDelta.
+	Type PayloadType
+	// Properties maps the dt.meta.* keys to their unquoted and unescaped values.
+	Properties map[string]string
+}
+
+// Document is the result of parsing a multi-line payload.
+type Document struct {
+	Lines    []Line
+	Metadata []Metadata
+}
+
+// SyntaxError describes a violation of the line protocol at a position in the input.
+// Line and Column are 1-based; Column counts bytes.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// ErrorList is the list of all syntax errors found by Parse.
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0].Error(), len(l)-1)
+	}
+}
+
+// Parse parses newline-separated metric and metadata lines. Empty lines are skipped.
+// All valid lines are returned; if any line is invalid, the error is an ErrorList
+// with one SyntaxError per invalid line.
+func Parse(text string) (Document, error) {
+	doc := Document{Lines: []Line{}, Metadata: []Metadata{}}
+	errs := ErrorList{}
+
+	for i, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(raw, "#") {
+			meta, err := ParseMetadata(raw)
+			if err != nil {
+				errs = append(errs, atLine(err, i+1))
+				continue
+			}
+			doc.Metadata = append(doc.Metadata, meta)
+			continue
+		}
+
+		line, err := ParseLine(raw)
+		if err != nil {
+			errs = append(errs, atLine(err, i+1))
+			continue
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+
+	if len(errs) > 0 {
+		return doc, errs
+	}
+	return doc, nil
+}
+
+// atLine sets the line of a syntax error. Other errors are wrapped in a syntax error without column.
+func atLine(err error, line int) *SyntaxError {
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		return &SyntaxError{Line: line, Msg: err.Error()}
+	}
+	syntaxErr.Line = line
+	return syntaxErr
+}
+
+// ParseLine parses a single metric line. Errors are of type *SyntaxError with Line set to 1.
+func ParseLine(s string) (Line, error) {
+	p := &parser{s: s}
+	if len(s) > MaxLineLength {
+		return Line{}, p.errorf(0, "line exceeds %d characters", MaxLineLength)
+	}
+
+	line := Line{Dimensions: []Dimension{}}
+	key, err := p.metricKey()
+	if err != nil {
+		return Line{}, err
+	}
+	line.Key = key
+
+	for p.peek() == ',' {
+		p.pos++
+		dim, err := p.dimension()
+		if err != nil {
+			return Line{}, err
+		}
+		line.Dimensions = append(line.Dimensions, dim)
+	}
+
+	if err := p.expect(' '); err != nil {
+		return Line{}, err
+	}
+
+	start := p.pos
+	payload, err := parsePayload(p.until(' '))
+	if err != nil {
+		return Line{}, p.errorf(start, "%s", err.Error())
+	}
+	line.Payload = payload
+
+	if p.done() {
+		return line, nil
+	}
+	if err := p.expect(' '); err != nil {
+		return Line{}, err
+	}
+
+	start = p.pos
+	ts := p.until(' ')
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || ms < 0 {
+		return Line{}, p.errorf(start, "invalid timestamp %q", ts)
+	}
+	line.Timestamp = time.Unix(0, ms*int64(time.Millisecond))
+
+	if !p.done() {
+		return Line{}, p.errorf(p.pos, "unexpected trailing characters")
+	}
+	return line, nil
+}
+
+// ParseMetadata parses a single metadata line. Errors are of type *SyntaxError with Line set to 1.
+func ParseMetadata(s string) (Metadata, error) {
+	p := &parser{s: s}
+	if err := p.expect('#'); err != nil {
+		return Metadata{}, err
+	}
+
+	key, err := p.metricKey()
+	if err != nil {
+		return Metadata{}, err
+	}
+	meta := Metadata{Key: key, Properties: map[string]string{}}
+
+	if err := p.expect(' '); err != nil {
+		return Metadata{}, err
+	}
+	start := p.pos
+	switch typ := p.until(' '); typ {
+	case "gauge":
+		meta.Type = Gauge
+	case "count":
+		meta.Type = CountDelta
+	default:
+		return Metadata{}, p.errorf(start, "invalid metadata type %q, expected gauge or count", typ)
+	}
+
+	if p.done() {
+		return meta, nil
+	}
+	if err := p.expect(' '); err != nil {
+		return Metadata{}, err
+	}
+
+	for {
+		start := p.pos
+		dim, err := p.dimension()
+		if err != nil {
+			return Metadata{}, err
+		}
+		if !strings.HasPrefix(dim.Key, "dt.meta.") {
+			return Metadata{}, p.errorf(start, "invalid metadata property %q, expected dt.meta.*", dim.Key)
+		}
+		meta.Properties[dim.Key] = dim.Value
+
+		if p.done() {
+			return meta, nil
+		}
+		if err := p.expect(','); err != nil {
+			return Metadata{}, err
+		}
+	}
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Line: 1, Column: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		if p.done() {
+			return p.errorf(p.pos, "unexpected end of line, expected %q", c)
+		}
+		return p.errorf(p.pos, "unexpected %q, expected %q", p.peek(), c)
+	}
+	p.pos++
+	return nil
+}
+
+// until consumes and returns everything up to, but not including, the next occurrence of any of stops.
+func (p *parser) until(stops ...byte) string {
+	start := p.pos
+	for !p.done() && !isOneOf(p.s[p.pos], stops) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) metricKey() (string, error) {
+	start := p.pos
+	key := p.until(',', ' ')
+	if key == "" {
+		return "", p.errorf(start, "metric key is empty")
+	}
+	if len(key) > MaxMetricKeyLength {
+		return "", p.errorf(start, "metric key exceeds %d characters", MaxMetricKeyLength)
+	}
+	if !reMetricKey.MatchString(key) {
+		return "", p.errorf(start, "invalid metric key %q", key)
+	}
+	return key, nil
+}
+
+func (p *parser) dimension() (Dimension, error) {
+	start := p.pos
+	key := p.until('=', ',', ' ')
+	if len(key) > MaxDimensionKeyLength {
+		return Dimension{}, p.errorf(start, "dimension key exceeds %d characters", MaxDimensionKeyLength)
+	}
+	if !reDimensionKey.MatchString(key) {
+		return Dimension{}, p.errorf(start, "invalid dimension key %q", key)
+	}
+	if err := p.expect('='); err != nil {
+		return Dimension{}, err
+	}
+
+	start = p.pos
+	value, err := p.dimensionValue()
+	if err != nil {
+		return Dimension{}, err
+	}
+	if p.pos-start > MaxDimensionValueLength {
+		return Dimension{}, p.errorf(start, "value of dimension %q exceeds %d characters", key, MaxDimensionValueLength)
+	}
+	return Dimension{Key: key, Value: value}, nil
+}
+
+// dimensionValue consumes a plain value with backslash escapes, or a value enclosed in double quotes.
+func (p *parser) dimensionValue() (string, error) {
+	start := p.pos
+	quoted := p.peek() == '"'
+	if quoted {
+		p.pos++
+	}
+
+	var sb strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\':
+			if p.pos+1 >= len(p.s) {
+				return "", p.errorf(p.pos, "dangling escape character")
+			}
+			sb.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+			continue
+		case quoted && c == '"':
+			p.pos++
+			return sb.String(), nil
+		case !quoted && (c == ',' || c == ' '):
+			return sb.String(), nil
+		case !quoted && (c == '"' || c == '='):
+			return "", p.errorf(p.pos, "unescaped %q in dimension value", c)
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+
+	if quoted {
+		return "", p.errorf(start, "unterminated quoted dimension value")
+	}
+	return sb.String(), nil
+}
+
+func parsePayload(s string) (Payload, error) {
+	parts := strings.Split(s, ",")
+	switch parts[0] {
+	case "gauge":
+		if len(parts) == 2 && !strings.Contains(parts[1], "=") {
+			value, err := parseNumber(parts[1])
+			return Payload{Type: Gauge, Value: value}, err
+		}
+		return parseSummary(s, parts[1:])
+	case "count":
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], "delta=") {
+			return Payload{}, fmt.Errorf("invalid count payload %q, expected count,delta=<value>", s)
+		}
+		value, err := parseNumber(strings.TrimPrefix(parts[1], "delta="))
+		return Payload{Type: CountDelta, Value: value}, err
+	default:
+		return Payload{}, fmt.Errorf("invalid payload type %q, expected gauge or count", parts[0])
+	}
+}
+
+func parseSummary(s string, fields []string) (Payload, error) {
+	if len(fields) != 4 {
+		return Payload{}, fmt.Errorf("invalid summary payload %q, expected gauge,min=<v>,max=<v>,sum=<v>,count=<n>", s)
+	}
+
+	payload := Payload{Type: Summary}
+	for i, name := range []string{"min", "max", "sum"} {
+		if !strings.HasPrefix(fields[i], name+"=") {
+			return Payload{}, fmt.Errorf("invalid summary payload %q, expected %s", s, name)
+		}
+		value, err := parseNumber(strings.TrimPrefix(fields[i], name+"="))
+		if err != nil {
+			return Payload{}, err
+		}
+		switch name {
+		case "min":
+			payload.Min = value
+		case "max":
+			payload.Max = value
+		case "sum":
+			payload.Sum = value
+		}
+	}
+
+	if !strings.HasPrefix(fields[3], "count=") {
+		return Payload{}, fmt.Errorf("invalid summary payload %q, expected count", s)
+	}
+	count, err := strconv.ParseInt(strings.TrimPrefix(fields[3], "count="), 10, 64)
+	if err != nil || count < 0 {
+		return Payload{}, fmt.Errorf("invalid summary count in %q", s)
+	}
+	payload.Count = count
+
+	if payload.Min.Float64() > payload.Max.Float64() {
+		return Payload{}, fmt.Errorf("summary min is greater than max in %q", s)
+	}
+	return payload, nil
+}
+
+func parseNumber(s string) (Number, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Number{IsInt: true, Int: i}, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || strings.ContainsAny(s, "xXpP_") {
+		return Number{}, fmt.Errorf("invalid number %q", s)
+	}
+	if strings.ContainsAny(s, "eE") && !strings.Contains(s, ".") {
+		return Number{}, fmt.Errorf("invalid number %q, scientific notation requires a decimal point", s)
+	}
+	return Number{Float: f}, nil
+}
+
+func isOneOf(c byte, set []byte) bool {
+	for _, s := range set {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
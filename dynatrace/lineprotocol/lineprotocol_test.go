@@ -0,0 +1,190 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lineprotocol
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dtMetric "github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	line, err := ParseLine(`my.metric,dim=a\ b\,c,quoted="x y",empty= count,delta=11 1656061000000`)
+	require.NoError(t, err)
+	require.Equal(t, Line{
+		Key: "my.metric",
+		Dimensions: []Dimension{
+			{Key: "dim", Value: "a b,c"},
+			{Key: "quoted", Value: "x y"},
+			{Key: "empty", Value: ""},
+		},
+		Payload:   Payload{Type: CountDelta, Value: Number{IsInt: true, Int: 11}},
+		Timestamp: time.Unix(1656061000, 0),
+	}, line)
+
+	line, err = ParseLine("name gauge,min=1.5,max=2.0e3,sum=-3,count=2")
+	require.NoError(t, err)
+	require.Equal(t, Payload{
+		Type:  Summary,
+		Min:   Number{Float: 1.5},
+		Max:   Number{Float: 2000},
+		Sum:   Number{IsInt: true, Int: -3},
+		Count: 2,
+	}, line.Payload)
+	require.True(t, line.Timestamp.IsZero())
+
+	line, err = ParseLine("name gauge,9007199254740993")
+	require.NoError(t, err)
+	require.Equal(t, int64(9007199254740993), line.Payload.Value.Int)
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	tests := map[string]struct {
+		column int
+		msg    string
+	}{
+		"":                                     {1, "metric key is empty"},
+		"1name gauge,1":                        {1, "invalid metric key"},
+		"name,Dim=v gauge,1":                   {6, "invalid dimension key"},
+		"name,dim gauge,1":                     {9, `expected '='`},
+		`name,dim="open gauge,1`:               {10, "unterminated quoted"},
+		"name":                                 {5, "unexpected end of line"},
+		"name histogram,1":                     {6, "invalid payload type"},
+		"name gauge,NaN":                       {6, "invalid number"},
+		"name gauge,1e5":                       {6, "requires a decimal point"},
+		"name count,3":                         {6, "expected count,delta=<value>"},
+		"name gauge,min=2,max=1,sum=3,count=2": {6, "min is greater than max"},
+		"name gauge,1 yesterday":               {14, "invalid timestamp"},
+		"name gauge,1 1 2":                     {15, "unexpected trailing characters"},
+	}
+
+	for input, expect := range tests {
+		_, err := ParseLine(input)
+		require.Error(t, err, input)
+		syntaxErr, ok := err.(*SyntaxError)
+		require.True(t, ok, input)
+		require.Equal(t, expect.column, syntaxErr.Column, input)
+		require.Contains(t, syntaxErr.Msg, expect.msg, input)
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	meta, err := ParseMetadata(`#my.metric gauge dt.meta.unit=Byte,dt.meta.description="The size, in bytes"`)
+	require.NoError(t, err)
+	require.Equal(t, Metadata{
+		Key:  "my.metric",
+		Type: Gauge,
+		Properties: map[string]string{
+			"dt.meta.unit":        "Byte",
+			"dt.meta.description": "The size, in bytes",
+		},
+	}, meta)
+
+	_, err = ParseMetadata("#my.metric count other=1")
+	require.EqualError(t, err, `line 1, column 18: invalid metadata property "other", expected dt.meta.*`)
+}
+
+func TestParse(t *testing.T) {
+	doc, err := Parse("#name count dt.meta.unit=Count\nname count,delta=1\n\nbroken\nname gauge,2\nname gauge,x")
+	require.Len(t, doc.Metadata, 1)
+	require.Len(t, doc.Lines, 2)
+
+	errs, ok := err.(ErrorList)
+	require.True(t, ok)
+	require.Len(t, errs, 2)
+	require.Equal(t, 4, errs[0].Line)
+	require.Equal(t, 6, errs[1].Line)
+	require.EqualError(t, err, `line 4, column 7: unexpected end of line, expected ' ' (and 1 more errors)`)
+}
+
+// TestParse_RoundTrip serializes random metrics with dynatrace-metric-utils and checks that parsing
+// the result yields the same key, dimensions, values and timestamp.
+func TestParse_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	charset := "abcXYZ019_-. =,\\\"é"
+	randString := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			sb.WriteString(string([]rune(charset)[r.Intn(len([]rune(charset)))]))
+		}
+		return sb.String()
+	}
+
+	for i := 0; i < 1000; i++ {
+		dims := []dimensions.Dimension{}
+		for j := r.Intn(4); j > 0; j-- {
+			dims = append(dims, dimensions.NewDimension("dim"+randString(3), randString(1+r.Intn(10))))
+		}
+		normalized := dimensions.MergeLists(dimensions.NewNormalizedDimensionList(dims...))
+
+		value := (r.Float64() - 0.5) * 1e6
+		count := r.Int63n(1000)
+		timestamp := time.Unix(1656061000+r.Int63n(1000), 0)
+		var opt dtMetric.MetricOption
+		expect := Payload{}
+		switch r.Intn(3) {
+		case 0:
+			opt = dtMetric.WithFloatGaugeValue(value)
+			expect = Payload{Type: Gauge, Value: Number{Float: value}}
+		case 1:
+			opt = dtMetric.WithFloatCounterValueDelta(value)
+			expect = Payload{Type: CountDelta, Value: Number{Float: value}}
+		case 2:
+			opt = dtMetric.WithIntSummaryValue(-count, count, count*2, count)
+			expect = Payload{Type: Summary, Min: Number{IsInt: true, Int: -count}, Max: Number{IsInt: true, Int: count}, Sum: Number{IsInt: true, Int: count * 2}, Count: count}
+		}
+
+		m, err := dtMetric.NewMetric("name."+randString(5), dtMetric.WithDimensions(normalized), dtMetric.WithTimestamp(timestamp), opt)
+		require.NoError(t, err)
+		serialized, err := m.Serialize()
+		require.NoError(t, err)
+
+		line, err := ParseLine(serialized)
+		require.NoError(t, err, serialized)
+		require.Equal(t, timestamp, line.Timestamp, serialized)
+		require.Equal(t, expect.Type, line.Payload.Type, serialized)
+		require.Equal(t, expect.Value.Float64(), line.Payload.Value.Float64(), serialized)
+		require.Equal(t, expect.Min.Float64(), line.Payload.Min.Float64(), serialized)
+		require.Equal(t, expect.Count, line.Payload.Count, serialized)
+		require.Equal(t, serialized, format(line), serialized)
+	}
+}
+
+// format serializes a parsed line again, escaping dimension values the same way the normalizer does.
+func format(l Line) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `=`, `\=`, ` `, `\ `, `,`, `\,`, `"`, `\"`)
+	var sb strings.Builder
+	sb.WriteString(l.Key)
+	for _, dim := range l.Dimensions {
+		sb.WriteString("," + dim.Key + "=" + escaper.Replace(dim.Value))
+	}
+	sb.WriteString(" " + l.Payload.String())
+	if !l.Timestamp.IsZero() {
+		sb.WriteString(" " + strconv.FormatInt(l.Timestamp.UnixNano()/int64(time.Millisecond), 10))
+	}
+	return sb.String()
+}
+
+func TestAtLine(t *testing.T) {
+	require.Equal(t, &SyntaxError{Line: 3, Column: 2, Msg: "msg"}, atLine(&SyntaxError{Line: 1, Column: 2, Msg: "msg"}, 3))
+	require.Equal(t, &SyntaxError{Line: 3, Msg: "other"}, atLine(errors.New("other"), 3))
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+)
+
+// Logger is the logging interface of the exporter. The key-value pairs alternate between string keys
+// and arbitrary values, like the sugared zap logger, slog and logr expect them.
+// Adapters for these libraries are provided by NewZapLogger, NewSlogLogger and NewLogrLogger.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NewZapLogger returns a Logger writing to the zap logger. The caller reported by zap is the caller of the Logger.
+// A nil logger discards all messages.
+func NewZapLogger(logger *zap.Logger) Logger {
+	if logger == nil {
+		return NewNopLogger()
+	}
+	return zapLogger{logger.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+type zapLogger struct {
+	s *zap.SugaredLogger
+}
+
+func (l zapLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.s.Debugw(msg, keysAndValues...)
+}
+
+func (l zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.s.Infow(msg, keysAndValues...)
+}
+
+func (l zapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.s.Warnw(msg, keysAndValues...)
+}
+
+func (l zapLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.s.Errorw(msg, keysAndValues...)
+}
+
+// NewLogrLogger returns a Logger writing to the logr logger. Debug messages are logged at verbosity level 1,
+// warnings are logged as info messages. For errors, a value of type error under the "error" key
+// is passed to logr as the error.
+func NewLogrLogger(logger logr.Logger) Logger {
+	return logrLogger{logger}
+}
+
+type logrLogger struct {
+	l logr.Logger
+}
+
+func (l logrLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.l.V(1).Info(msg, keysAndValues...)
+}
+
+func (l logrLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.l.Info(msg, keysAndValues...)
+}
+
+func (l logrLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.l.Info(msg, keysAndValues...)
+}
+
+func (l logrLogger) Error(msg string, keysAndValues ...interface{}) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if err, ok := keysAndValues[i+1].(error); ok && keysAndValues[i] == "error" {
+			rest := make([]interface{}, 0, len(keysAndValues)-2)
+			rest = append(rest, keysAndValues[:i]...)
+			rest = append(rest, keysAndValues[i+2:]...)
+			l.l.Error(err, msg, rest...)
+			return
+		}
+	}
+	l.l.Error(nil, msg, keysAndValues...)
+}
+
+// NewNopLogger returns a Logger that discards all messages.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
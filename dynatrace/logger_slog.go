@@ -0,0 +1,48 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package dynatrace
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a Logger writing to the slog logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (l slogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (l slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (l slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (l slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
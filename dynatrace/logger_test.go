@@ -0,0 +1,114 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewZapLogger(zap.New(core, zap.AddCaller()))
+
+	logger.Debug("debug", "k", 1)
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error", "error", errors.New("failed"))
+
+	entries := logs.All()
+	require.Len(t, entries, 4)
+	require.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	require.Equal(t, map[string]interface{}{"k": int64(1)}, entries[0].ContextMap())
+	require.Equal(t, zapcore.InfoLevel, entries[1].Level)
+	require.Equal(t, zapcore.WarnLevel, entries[2].Level)
+	require.Equal(t, zapcore.ErrorLevel, entries[3].Level)
+	require.Equal(t, "failed", entries[3].ContextMap()["error"])
+	for _, entry := range entries {
+		require.True(t, strings.HasSuffix(entry.Caller.File, "logger_test.go"), entry.Caller.File)
+	}
+
+	require.NotPanics(t, func() { NewZapLogger(nil).Error("error") })
+}
+
+func TestLogrLogger(t *testing.T) {
+	var lines []string
+	logger := NewLogrLogger(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 1}))
+
+	logger.Debug("debug", "k", 1)
+	logger.Warn("warn")
+	logger.Error("failed to send", "error", errors.New("timeout"), "url", "http://localhost")
+	logger.Error("error from Dynatrace", "message", "invalid token")
+
+	require.Len(t, lines, 4)
+	require.Contains(t, lines[0], `"level"=1 "msg"="debug" "k"=1`)
+	require.Contains(t, lines[1], `"level"=0 "msg"="warn"`)
+	require.Equal(t, `"msg"="failed to send" "error"="timeout" "url"="http://localhost"`, lines[2])
+	require.Equal(t, `"msg"="error from Dynatrace" "error"=null "message"="invalid token"`, lines[3])
+
+	lines = nil
+	quiet := NewLogrLogger(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{}))
+	quiet.Debug("debug")
+	require.Empty(t, lines)
+}
+
+func TestNewExporter_Log(t *testing.T) {
+	log := &recordingLogger{}
+	core, logs := observer.New(zapcore.DebugLevel)
+	_, err := NewExporter(Options{
+		URL:      "http://example.com/api/v2/metrics/ingest",
+		APIToken: "token",
+		Logger:   zap.New(core),
+		Log:      log,
+
+		DisableDynatraceMetadataEnrichment: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, logs.Len())
+	require.Len(t, log.messages, 1)
+	require.True(t, strings.HasPrefix(log.messages[0], "warn: API token will be sent unencrypted"))
+}
+
+// recordingLogger records the level and message of every log call.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) {
+	l.messages = append(l.messages, "debug: "+msg)
+}
+
+func (l *recordingLogger) Info(msg string, _ ...interface{}) {
+	l.messages = append(l.messages, "info: "+msg)
+}
+
+func (l *recordingLogger) Warn(msg string, _ ...interface{}) {
+	l.messages = append(l.messages, "warn: "+msg)
+}
+
+func (l *recordingLogger) Error(msg string, _ ...interface{}) {
+	l.messages = append(l.messages, "error: "+msg)
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+const (
+	// oneAgentEnrichmentDir is the directory in which the OneAgent places enrichment files on Linux.
+	oneAgentEnrichmentDir = "/var/lib/dynatrace/enrichment"
+	// oneAgentIndirectionFile contains the path of the process-specific metadata file.
+	oneAgentIndirectionFile = "dt_metadata_e617c525669e072eebe3d0f08212e8f2.properties"
+)
+
+// oneAgentMetadataFiles are read in order, so values from later files override earlier ones.
+var oneAgentMetadataFiles = []string{
+	"dt_host_metadata.json",
+	"dt_host_metadata.properties",
+	"dt_metadata.json",
+	"dt_metadata.properties",
+}
+
+// oneAgentRefreshInterval is the interval in which the OneAgent metadata is read again,
+// so that exporters pick up a OneAgent that is installed or updated after they were created.
+const oneAgentRefreshInterval = 5 * time.Minute
+
+// oneAgentSource returns the enrichment source of the OneAgent metadata of the current host and process.
+// Each exporter reads the metadata itself when it is created and again every oneAgentRefreshInterval.
+func oneAgentSource(logger Logger) enrichmentSource {
+	return enrichmentSource{
+		name: "OneAgent",
+		enrich: func(context.Context) ([]dimensions.Dimension, error) {
+			return readPlatformOneAgentMetadata(logger), nil
+		},
+		interval: oneAgentRefreshInterval,
+	}
+}
+
+// readOneAgentEnrichmentFiles reads the OneAgent enrichment files below root, which is "/" outside of tests.
+// It reads the well-known JSON and properties files in the enrichment directory and
+// the metadata file referenced by the indirection file. Missing files are skipped,
+// unreadable files are logged and skipped.
+func readOneAgentEnrichmentFiles(root string, logger Logger) []dimensions.Dimension {
+	dir := filepath.Join(root, oneAgentEnrichmentDir)
+	merged := map[string]string{}
+
+	files := []string{}
+	for _, name := range oneAgentMetadataFiles {
+		files = append(files, filepath.Join(dir, name))
+	}
+	if target, err := readIndirection(filepath.Join(dir, oneAgentIndirectionFile)); err == nil && target != "" {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(oneAgentEnrichmentDir, target)
+		}
+		files = append(files, filepath.Join(root, target))
+	} else if err != nil && !os.IsNotExist(err) {
+		logger.Debug("could not read OneAgent indirection file", "error", err)
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			logger.Warn("could not read OneAgent metadata file", "file", file, "error", err)
+			continue
+		}
+
+		var values map[string]string
+		if strings.HasSuffix(file, ".json") {
+			values, err = parseJSONMetadata(content)
+		} else {
+			values = parsePropertiesMetadata(content)
+		}
+		if err != nil {
+			logger.Warn("could not parse OneAgent metadata file", "file", file, "error", err)
+			continue
+		}
+
+		for k, v := range values {
+			merged[k] = v
+		}
+	}
+
+	return sortedDimensions(merged)
+}
+
+// sortedDimensions returns the values as dimensions, sorted by key.
+func sortedDimensions(values map[string]string) []dimensions.Dimension {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dims := make([]dimensions.Dimension, 0, len(keys))
+	for _, k := range keys {
+		dims = append(dims, dimensions.NewDimension(k, values[k]))
+	}
+	return dims
+}
+
+// readIndirection returns the first line of the indirection file, which is the name of the metadata file.
+func readIndirection(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text()), scanner.Err()
+}
+
+// parsePropertiesMetadata parses key=value lines. Empty lines, comments and malformed lines are ignored.
+func parsePropertiesMetadata(content []byte) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			continue
+		}
+		values[kv[0]] = kv[1]
+	}
+	return values
+}
+
+// parseJSONMetadata parses a flat JSON object. Numbers and booleans are converted to strings, nested values are ignored.
+func parseJSONMetadata(content []byte) (map[string]string, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case float64, bool:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
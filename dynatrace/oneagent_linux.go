@@ -0,0 +1,32 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package dynatrace
+
+import (
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+// readPlatformOneAgentMetadata reads the enrichment files directly, since the indirection file
+// in the working directory is only resolved by the OneAgent for processes using libc.
+func readPlatformOneAgentMetadata(logger Logger) []dimensions.Dimension {
+	dims := readOneAgentEnrichmentFiles("/", logger)
+	if len(dims) == 0 {
+		logger.Debug("No OneAgent metadata found. This is normal if no OneAgent is installed.")
+	}
+	return dims
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package dynatrace
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+)
+
+// readPlatformOneAgentMetadata uses the indirection file in the working directory,
+// which the OneAgent resolves for every process on Windows.
+func readPlatformOneAgentMetadata(logger Logger) []dimensions.Dimension {
+	target, err := readIndirection(oneAgentIndirectionFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Debug("could not read OneAgent indirection file", "error", err)
+		}
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(target)
+	if err != nil {
+		logger.Warn("could not read OneAgent metadata file", "file", target, "error", err)
+		return nil
+	}
+	return sortedDimensions(parsePropertiesMetadata(content))
+}
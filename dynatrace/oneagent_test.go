@@ -0,0 +1,81 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+)
+
+func writeEnrichmentFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, oneAgentEnrichmentDir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func Test_readOneAgentEnrichmentFiles(t *testing.T) {
+	t.Run("reads host and process metadata", func(t *testing.T) {
+		root := t.TempDir()
+		writeEnrichmentFile(t, root, "dt_host_metadata.json", `{"dt.entity.host": "HOST-1", "host.name": "from-json", "dt.smartscape.id": 42, "nested": {"a": "b"}}`)
+		writeEnrichmentFile(t, root, "dt_host_metadata.properties", "# comment\nhost.name=from-properties\nmalformed\n")
+		writeEnrichmentFile(t, root, oneAgentIndirectionFile, "dt_metadata_1234.properties\n")
+		writeEnrichmentFile(t, root, "dt_metadata_1234.properties", "dt.entity.process_group_instance=PGI-1\ndt.entity.host=HOST-2\n")
+
+		dims := readOneAgentEnrichmentFiles(root, NewNopLogger())
+		require.Equal(t, []dimensions.Dimension{
+			NewDimension("dt.entity.host", "HOST-2"),
+			NewDimension("dt.entity.process_group_instance", "PGI-1"),
+			NewDimension("dt.smartscape.id", "42"),
+			NewDimension("host.name", "from-properties"),
+		}, dims)
+	})
+
+	t.Run("follows absolute indirection", func(t *testing.T) {
+		root := t.TempDir()
+		writeEnrichmentFile(t, root, oneAgentIndirectionFile, filepath.Join(oneAgentEnrichmentDir, "dt_metadata_1.properties"))
+		writeEnrichmentFile(t, root, "dt_metadata_1.properties", "dt.entity.process_group_instance=PGI-1")
+
+		dims := readOneAgentEnrichmentFiles(root, NewNopLogger())
+		require.Equal(t, []dimensions.Dimension{NewDimension("dt.entity.process_group_instance", "PGI-1")}, dims)
+	})
+
+	t.Run("skips missing and invalid files", func(t *testing.T) {
+		root := t.TempDir()
+		require.Empty(t, readOneAgentEnrichmentFiles(root, NewNopLogger()))
+
+		writeEnrichmentFile(t, root, "dt_metadata.json", "{not json")
+		writeEnrichmentFile(t, root, oneAgentIndirectionFile, "missing.properties")
+		writeEnrichmentFile(t, root, "dt_metadata.properties", "host.name=valid")
+
+		dims := readOneAgentEnrichmentFiles(root, NewNopLogger())
+		require.Equal(t, []dimensions.Dimension{NewDimension("host.name", "valid")}, dims)
+	})
+}
+
+func Test_oneAgentSource(t *testing.T) {
+	source := oneAgentSource(NewNopLogger())
+	require.Equal(t, "OneAgent", source.name)
+	require.Equal(t, oneAgentRefreshInterval, source.interval)
+
+	_, err := source.enrich(context.Background())
+	require.NoError(t, err)
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// DefaultResourceAttributeMapping returns a new map from OpenTelemetry semantic convention resource attributes
+// of Kubernetes workloads to the dimensions Dynatrace uses to correlate metrics with Kubernetes entities.
+// It can be passed as Options.ResourceAttributeMapping, on its own or extended by custom entries.
+//
+// service.name, service.namespace, host.name and process.pid are not mapped. Dynatrace ties metrics to services,
+// hosts and processes only through entity ID dimensions, such as dt.entity.host and dt.entity.process_group_instance,
+// and these IDs cannot be derived from the attributes. The attributes are exported under their own keys, and on hosts
+// with a OneAgent, the metadata enrichment adds the entity IDs.
+func DefaultResourceAttributeMapping() map[string]string {
+	return map[string]string{
+		"k8s.cluster.name":     "dt.kubernetes.cluster.name",
+		"k8s.cluster.uid":      "dt.kubernetes.cluster.id",
+		"k8s.node.name":        "dt.kubernetes.node.name",
+		"k8s.container.name":   "dt.kubernetes.container.name",
+		"k8s.deployment.name":  "dt.kubernetes.workload.name",
+		"k8s.statefulset.name": "dt.kubernetes.workload.name",
+		"k8s.daemonset.name":   "dt.kubernetes.workload.name",
+		"k8s.replicaset.name":  "dt.kubernetes.workload.name",
+		"k8s.job.name":         "dt.kubernetes.workload.name",
+		"k8s.cronjob.name":     "dt.kubernetes.workload.name",
+	}
+}
+
+// resourceAttributePrecedence orders resource attributes that may be mapped to the same dimension.
+// The workload that owns the pod most directly comes first: a deployment over its replica set
+// and a cron job over its job. Attributes that are not listed rank after all listed ones.
+var resourceAttributePrecedence = []string{
+	"k8s.deployment.name",
+	"k8s.statefulset.name",
+	"k8s.daemonset.name",
+	"k8s.cronjob.name",
+	"k8s.job.name",
+	"k8s.replicaset.name",
+}
+
+// resourceAttributeRank returns the position of the attribute in resourceAttributePrecedence.
+func resourceAttributeRank(key string) int {
+	for i, k := range resourceAttributePrecedence {
+		if k == key {
+			return i
+		}
+	}
+	return len(resourceAttributePrecedence)
+}
+
+// copyMapping returns a copy of the mapping, so that later changes by the caller do not race with exports.
+func copyMapping(mapping map[string]string) map[string]string {
+	if mapping == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(mapping))
+	for k, v := range mapping {
+		copied[k] = v
+	}
+	return copied
+}
+
+// resourceDimensions converts the resource attributes to dimensions.
+// Attributes contained in the mapping are added under the mapped key, and also under their
+// original key unless DropMappedResourceAttributes is set. Mapped attributes of any type are
+// converted to strings, all other attributes are only added if they are strings.
+// If several attributes are mapped to the same key, the one ranked first by resourceAttributePrecedence wins,
+// and among attributes of equal rank the one with the smallest key.
+func (e *Exporter) resourceDimensions(res *resource.Resource) []dimensions.Dimension {
+	dims := []dimensions.Dimension{}
+	mapped := []dimensions.Dimension{}
+	ranks := map[string]int{}
+	index := map[string]int{}
+
+	iter := res.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		key := string(kv.Key)
+
+		target, ok := e.opts.ResourceAttributeMapping[key]
+		if !ok || target == "" {
+			if kv.Value.Type() == attribute.STRING {
+				dims = append(dims, NewDimension(key, kv.Value.AsString()))
+			}
+			continue
+		}
+
+		// The iterator returns the attributes sorted by key, so the first of equal rank is kept.
+		rank := resourceAttributeRank(key)
+		if i, ok := index[target]; !ok {
+			index[target] = len(mapped)
+			ranks[target] = rank
+			mapped = append(mapped, NewDimension(target, kv.Value.Emit()))
+		} else if rank < ranks[target] {
+			ranks[target] = rank
+			mapped[i] = NewDimension(target, kv.Value.Emit())
+		}
+		if !e.opts.DropMappedResourceAttributes && target != key && kv.Value.Type() == attribute.STRING {
+			dims = append(dims, NewDimension(key, kv.Value.AsString()))
+		}
+	}
+
+	// Mapped dimensions come last, so they win over original attributes with the same key.
+	return append(dims, mapped...)
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestExporter_resourceDimensions(t *testing.T) {
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "checkout"),
+		attribute.String("k8s.cluster.uid", "cluster-1"),
+		attribute.String("k8s.deployment.name", "checkout-deployment"),
+		attribute.String("custom", "value"),
+		attribute.Bool("flag", true),
+	)
+
+	t.Run("no mapping by default", func(t *testing.T) {
+		e, err := NewExporter(Options{DryRun: true, DisableDynatraceMetadataEnrichment: true})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []dimensions.Dimension{
+			NewDimension("custom", "value"),
+			NewDimension("k8s.cluster.uid", "cluster-1"),
+			NewDimension("k8s.deployment.name", "checkout-deployment"),
+			NewDimension("service.name", "checkout"),
+		}, e.resourceDimensions(res))
+	})
+
+	t.Run("default mapping keeps original keys", func(t *testing.T) {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			ResourceAttributeMapping:           DefaultResourceAttributeMapping(),
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []dimensions.Dimension{
+			NewDimension("custom", "value"),
+			NewDimension("k8s.cluster.uid", "cluster-1"),
+			NewDimension("k8s.deployment.name", "checkout-deployment"),
+			NewDimension("service.name", "checkout"),
+			NewDimension("dt.kubernetes.cluster.id", "cluster-1"),
+			NewDimension("dt.kubernetes.workload.name", "checkout-deployment"),
+		}, e.resourceDimensions(res))
+	})
+
+	t.Run("drop original keys", func(t *testing.T) {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			ResourceAttributeMapping:           DefaultResourceAttributeMapping(),
+			DropMappedResourceAttributes:       true,
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []dimensions.Dimension{
+			NewDimension("custom", "value"),
+			NewDimension("service.name", "checkout"),
+			NewDimension("dt.kubernetes.cluster.id", "cluster-1"),
+			NewDimension("dt.kubernetes.workload.name", "checkout-deployment"),
+		}, e.resourceDimensions(res))
+	})
+
+	t.Run("custom mapping", func(t *testing.T) {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			DropMappedResourceAttributes:       true,
+			ResourceAttributeMapping:           map[string]string{"custom": "renamed", "flag": "flag"},
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []dimensions.Dimension{
+			NewDimension("k8s.cluster.uid", "cluster-1"),
+			NewDimension("k8s.deployment.name", "checkout-deployment"),
+			NewDimension("service.name", "checkout"),
+			NewDimension("renamed", "value"),
+			NewDimension("flag", "true"),
+		}, e.resourceDimensions(res))
+	})
+
+	t.Run("mapping is copied", func(t *testing.T) {
+		mapping := map[string]string{"custom": "renamed"}
+		e, err := NewExporter(Options{DryRun: true, DisableDynatraceMetadataEnrichment: true, ResourceAttributeMapping: mapping})
+		require.NoError(t, err)
+		mapping["custom"] = "changed"
+		require.Contains(t, e.resourceDimensions(res), NewDimension("renamed", "value"))
+	})
+
+	t.Run("workload precedence", func(t *testing.T) {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DisableDynatraceMetadataEnrichment: true,
+			ResourceAttributeMapping:           DefaultResourceAttributeMapping(),
+			DropMappedResourceAttributes:       true,
+		})
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			attrs []attribute.KeyValue
+			want  string
+		}{
+			{[]attribute.KeyValue{attribute.String("k8s.replicaset.name", "rs"), attribute.String("k8s.deployment.name", "deploy")}, "deploy"},
+			{[]attribute.KeyValue{attribute.String("k8s.job.name", "job"), attribute.String("k8s.cronjob.name", "cron")}, "cron"},
+			{[]attribute.KeyValue{attribute.String("k8s.statefulset.name", "sts"), attribute.String("k8s.replicaset.name", "rs")}, "sts"},
+		} {
+			require.Equal(t, []dimensions.Dimension{NewDimension("dt.kubernetes.workload.name", tt.want)},
+				e.resourceDimensions(resource.NewSchemaless(tt.attrs...)))
+		}
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// InvalidValuePolicy selects how values the ingest API would reject are handled.
+type InvalidValuePolicy int
+
+const (
+	// DropInvalidValues drops lines with NaN or infinite values and counters with negative deltas.
+	DropInvalidValues InvalidValuePolicy = iota
+	// ClampInvalidValues replaces infinite values by the largest finite value of the same sign,
+	// and negative counter deltas by zero. Lines with NaN values are still dropped.
+	ClampInvalidValues
+)
+
+// Reasons for sanitizing a value, as returned by SanitizedValues.
+const (
+	SanitizedNaN           = "nan"
+	SanitizedInfinity      = "infinity"
+	SanitizedNegativeDelta = "negative_delta"
+)
+
+// sanitizeLogInterval limits how often each kind of invalid value is logged.
+const sanitizeLogInterval = time.Minute
+
+// sanitizeCounter counts the sanitized values by reason and limits how often they are logged.
+type sanitizeCounter struct {
+	sync.Mutex
+	total   map[string]int64
+	pending map[string]int64
+	logged  map[string]time.Time
+}
+
+// add counts a sanitized value and returns the number of values to log, or zero if logging is rate limited.
+func (c *sanitizeCounter) add(reason string, now time.Time) int64 {
+	c.Lock()
+	defer c.Unlock()
+	if c.total == nil {
+		c.total = map[string]int64{}
+		c.pending = map[string]int64{}
+		c.logged = map[string]time.Time{}
+	}
+	c.total[reason]++
+	c.pending[reason]++
+
+	if last, ok := c.logged[reason]; ok && now.Sub(last) < sanitizeLogInterval {
+		return 0
+	}
+	c.logged[reason] = now
+	count := c.pending[reason]
+	c.pending[reason] = 0
+	return count
+}
+
+// SanitizedValues returns the number of values that were dropped or clamped since the exporter was created,
+// by reason.
+func (e *Exporter) SanitizedValues() map[string]int64 {
+	e.sanitized.Lock()
+	defer e.sanitized.Unlock()
+	counts := make(map[string]int64, len(e.sanitized.total))
+	for k, v := range e.sanitized.total {
+		counts[k] = v
+	}
+	return counts
+}
+
+// sanitizeValue checks a value of the metric before it is serialized. Counter deltas must not be negative.
+// It returns the value to serialize, or false if the line has to be dropped.
+func (e *Exporter) sanitizeValue(name string, value number.Number, kind number.Kind, counter bool) (number.Number, bool) {
+	f := value.CoerceToFloat64(kind)
+	switch {
+	case math.IsNaN(f):
+		e.reportSanitized(name, SanitizedNaN, "dropped")
+		return value, false
+	case math.IsInf(f, 0):
+		if e.opts.InvalidValues != ClampInvalidValues {
+			e.reportSanitized(name, SanitizedInfinity, "dropped")
+			return value, false
+		}
+		e.reportSanitized(name, SanitizedInfinity, "clamped")
+		if f > 0 {
+			return number.NewFloat64Number(math.MaxFloat64), true
+		}
+		return number.NewFloat64Number(-math.MaxFloat64), true
+	case counter && value.IsNegative(kind):
+		if e.opts.InvalidValues != ClampInvalidValues {
+			e.reportSanitized(name, SanitizedNegativeDelta, "dropped")
+			return value, false
+		}
+		e.reportSanitized(name, SanitizedNegativeDelta, "clamped")
+		return kind.Zero(), true
+	}
+	return value, true
+}
+
+func (e *Exporter) reportSanitized(name, reason, action string) {
+	e.telemetry.valueSanitized(context.Background(), reason, action)
+	if action == "dropped" {
+		e.telemetry.lineDropped(context.Background(), reason)
+	}
+	if count := e.sanitized.add(reason, e.currentTime()); count > 0 {
+		e.logger.Warn("invalid value "+action,
+			"name", name,
+			"reason", reason,
+			"count", count)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExporter_Export_InvalidValues(t *testing.T) {
+	records := func() map[instrumentation.Library][]export.Record {
+		return map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: {
+				counterRecord(t, "valid", 1),
+				counterRecord(t, "nan", math.NaN()),
+				counterRecord(t, "inf", math.Inf(1)),
+				counterRecord(t, "negative", -5),
+				gaugeRecord(t, "gauge.negative", -5),
+				gaugeRecord(t, "gauge.inf", math.Inf(-1)),
+				aggregationRecord("summary.nan", number.Float64Kind, testMinMaxSumCount{
+					min:   number.NewFloat64Number(1),
+					max:   number.NewFloat64Number(2),
+					sum:   number.NewFloat64Number(math.NaN()),
+					count: 2,
+				}),
+			},
+		}
+	}
+
+	run := func(t *testing.T, policy InvalidValuePolicy) ([]string, *Exporter, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.WarnLevel)
+		lines := []string{}
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DryRunHandler:                      func(r DryRunResult) { lines = append(lines, r.Valid...) },
+			DisableDynatraceMetadataEnrichment: true,
+			Logger:                             zap.New(core),
+			InvalidValues:                      policy,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		require.NoError(t, e.Export(context.Background(), resource.Empty(), processortest.MultiInstrumentationLibraryReader(records())))
+		return lines, e, logs
+	}
+
+	t.Run("invalid values are dropped by default", func(t *testing.T) {
+		lines, e, logs := run(t, DropInvalidValues)
+		require.ElementsMatch(t, []string{
+			"valid,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"gauge.negative,dt.metrics.source=opentelemetry gauge,-5 " + intervalEndMillis,
+		}, lines)
+		require.Equal(t, map[string]int64{
+			SanitizedNaN:           2,
+			SanitizedInfinity:      2,
+			SanitizedNegativeDelta: 1,
+		}, e.SanitizedValues())
+		// Each reason is logged once.
+		require.Equal(t, 3, logs.FilterMessage("invalid value dropped").Len())
+	})
+
+	t.Run("invalid values are clamped", func(t *testing.T) {
+		lines, e, logs := run(t, ClampInvalidValues)
+		require.ElementsMatch(t, []string{
+			"valid,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"inf,dt.metrics.source=opentelemetry count,delta=1.7976931348623157e+308 " + intervalEndMillis,
+			"negative,dt.metrics.source=opentelemetry count,delta=0 " + intervalEndMillis,
+			"gauge.negative,dt.metrics.source=opentelemetry gauge,-5 " + intervalEndMillis,
+			"gauge.inf,dt.metrics.source=opentelemetry gauge,-1.7976931348623157e+308 " + intervalEndMillis,
+		}, lines)
+		require.Equal(t, map[string]int64{
+			SanitizedNaN:           2,
+			SanitizedInfinity:      2,
+			SanitizedNegativeDelta: 1,
+		}, e.SanitizedValues())
+		require.Equal(t, 1, logs.FilterMessage("invalid value dropped").Len())
+		require.Equal(t, 2, logs.FilterMessage("invalid value clamped").Len())
+	})
+}
+
+func TestSanitizeCounter_add(t *testing.T) {
+	c := sanitizeCounter{}
+	start := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, int64(1), c.add(SanitizedNaN, start))
+	require.Zero(t, c.add(SanitizedNaN, start.Add(time.Second)))
+	require.Zero(t, c.add(SanitizedNaN, start.Add(30*time.Second)))
+	require.Equal(t, int64(1), c.add(SanitizedInfinity, start.Add(30*time.Second)))
+	// The occurrences since the last log are reported with the next one.
+	require.Equal(t, int64(3), c.add(SanitizedNaN, start.Add(sanitizeLogInterval)))
+	require.Equal(t, map[string]int64{SanitizedNaN: 4, SanitizedInfinity: 1}, c.total)
+}
+
+func TestExporter_Export_ClampedValuesAreScaled(t *testing.T) {
+	lines := []string{}
+	e, err := NewExporter(Options{
+		DryRun:                             true,
+		DryRunHandler:                      func(r DryRunResult) { lines = append(lines, r.Valid...) },
+		DisableDynatraceMetadataEnrichment: true,
+		InvalidValues:                      ClampInvalidValues,
+		Units:                              UnitOptions{ConvertToCanonical: true},
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	sumRecord := func(name string, kind sdkapi.InstrumentKind, u string, value float64) export.Record {
+		desc := sdkapi.NewDescriptor(name, kind, number.Float64Kind, "", unit.Unit(u))
+		sums := sum.New(2)
+		require.NoError(t, sums[0].Update(context.Background(), number.NewFloat64Number(value), &desc))
+		require.NoError(t, sums[0].SynchronizedMove(&sums[1], &desc))
+		return export.NewRecord(&desc, attribute.EmptySet(), sums[1].Aggregation(), intervalStart, intervalEnd)
+	}
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			sumRecord("uptime", sdkapi.CounterInstrumentKind, "min", math.Inf(1)),
+			sumRecord("backlog", sdkapi.UpDownCounterInstrumentKind, "min", math.Inf(-1)),
+			sumRecord("duration", sdkapi.CounterInstrumentKind, "ms", math.Inf(1)),
+		},
+	})))
+	require.ElementsMatch(t, []string{
+		"uptime,dt.metrics.source=opentelemetry count,delta=1.7976931348623157e+308 " + intervalEndMillis,
+		"backlog,dt.metrics.source=opentelemetry gauge,-1.7976931348623157e+308 " + intervalEndMillis,
+		"duration,dt.metrics.source=opentelemetry count,delta=1.7976931348623156e+305 " + intervalEndMillis,
+	}, lines)
+}
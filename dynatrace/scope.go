@@ -0,0 +1,73 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+)
+
+// Default dimension keys of the instrumentation scope.
+const (
+	DefaultScopeNameDimension    = "otel.scope.name"
+	DefaultScopeVersionDimension = "otel.scope.version"
+)
+
+// ScopeRule changes how the metrics of a single instrumentation scope are exported.
+type ScopeRule struct {
+	// Scope is the name of the instrumentation scope the rule applies to.
+	Scope string
+	// Drop drops all metrics of the scope.
+	Drop bool
+	// Rename maps metric names of the scope to the names they are exported as.
+	Rename map[string]string
+}
+
+// scopeRule returns the first rule for the scope, or nil if there is none.
+func (e *Exporter) scopeRule(scope instrumentation.Library) *ScopeRule {
+	for i := range e.opts.ScopeRules {
+		if e.opts.ScopeRules[i].Scope == scope.Name {
+			return &e.opts.ScopeRules[i]
+		}
+	}
+	return nil
+}
+
+// scopeDimensions returns the name and version of the scope as dimensions if AddScopeDimensions is set.
+func (e *Exporter) scopeDimensions(scope instrumentation.Library) dimensionList {
+	if !e.opts.AddScopeDimensions {
+		return dimensionList{}
+	}
+
+	dims := []dimensions.Dimension{}
+	if scope.Name != "" {
+		dims = append(dims, dimensions.NewDimension(e.opts.ScopeNameDimension, scope.Name))
+	}
+	if scope.Version != "" {
+		dims = append(dims, dimensions.NewDimension(e.opts.ScopeVersionDimension, scope.Version))
+	}
+	return e.newDimensionList(dims...)
+}
+
+// metricName returns the name a metric of the scope is exported as.
+func (r *ScopeRule) metricName(name string) string {
+	if r == nil {
+		return name
+	}
+	if renamed, ok := r.Rename[name]; ok {
+		return renamed
+	}
+	return name
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestExporter_Export_Scope(t *testing.T) {
+	records := func() map[instrumentation.Library][]export.Record {
+		return map[instrumentation.Library][]export.Record{
+			{Name: "lib.a", Version: "1.2.3"}: {counterRecord(t, "requests", 1)},
+			{Name: "lib.b"}:                   {counterRecord(t, "requests", 2), counterRecord(t, "errors", 3)},
+		}
+	}
+
+	t.Run("scope is ignored by default", func(t *testing.T) {
+		lines := exportLines(t, Options{}, resource.Empty(), records())
+		require.ElementsMatch(t, []string{
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"requests,dt.metrics.source=opentelemetry count,delta=2 " + intervalEndMillis,
+			"errors,dt.metrics.source=opentelemetry count,delta=3 " + intervalEndMillis,
+		}, lines)
+	})
+
+	t.Run("adds scope dimensions", func(t *testing.T) {
+		lines := exportLines(t, Options{AddScopeDimensions: true}, resource.Empty(), records())
+		require.Len(t, lines, 3)
+		require.Contains(t, lines[0]+lines[1]+lines[2], "otel.scope.name=lib.a")
+		require.Contains(t, lines[0]+lines[1]+lines[2], "otel.scope.version=1.2.3")
+		require.Contains(t, lines[0]+lines[1]+lines[2], "otel.scope.name=lib.b")
+		require.NotContains(t, lines[0]+lines[1]+lines[2], "otel.scope.version=,")
+	})
+
+	t.Run("custom dimension keys", func(t *testing.T) {
+		lines := exportLines(t, Options{
+			AddScopeDimensions:    true,
+			ScopeNameDimension:    "library",
+			ScopeVersionDimension: "library.version",
+		}, resource.Empty(), map[instrumentation.Library][]export.Record{
+			{Name: "lib.a", Version: "1.2.3"}: {counterRecord(t, "requests", 1)},
+		})
+		require.Len(t, lines, 1)
+		require.Contains(t, lines[0], "library=lib.a")
+		require.Contains(t, lines[0], "library.version=1.2.3")
+		require.NotContains(t, lines[0], "otel.scope")
+	})
+
+	t.Run("drops and renames per scope", func(t *testing.T) {
+		lines := exportLines(t, Options{ScopeRules: []ScopeRule{
+			{Scope: "lib.a", Drop: true},
+			{Scope: "lib.b", Rename: map[string]string{"requests": "lib.b.requests"}},
+		}}, resource.Empty(), records())
+		require.ElementsMatch(t, []string{
+			"lib.b.requests,dt.metrics.source=opentelemetry count,delta=2 " + intervalEndMillis,
+			"errors,dt.metrics.source=opentelemetry count,delta=3 " + intervalEndMillis,
+		}, lines)
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.uber.org/multierr"
+)
+
+// instrumentationName is the name of the meter the exporter records its own metrics with.
+const instrumentationName = "github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace"
+
+// Reasons for dropping lines, as recorded in the reason attribute of dynatrace.exporter.lines.dropped.
+// Lines dropped because of invalid values use the reasons of SanitizedValues.
+const (
+	DroppedFiltered               = "filtered"
+	DroppedExpired                = "expired"
+	DroppedUnsupportedAggregation = "unsupported_aggregation"
+)
+
+var (
+	reasonKey     = attribute.Key("reason")
+	actionKey     = attribute.Key("action")
+	statusCodeKey = attribute.Key("http.status_code")
+)
+
+// selfTelemetry holds the instruments the exporter records its own behavior with.
+// There is no instrument for retries, as the exporter never retries a failed request.
+// Its methods do nothing on a nil selfTelemetry, as used by exporters not created with NewExporter.
+type selfTelemetry struct {
+	linesSerialized syncint64.Counter
+	linesDropped    syncint64.Counter
+	linesOk         syncint64.Counter
+	linesInvalid    syncint64.Counter
+	valuesSanitized syncint64.Counter
+	requests        syncint64.Counter
+	requestDuration syncfloat64.Histogram
+	bytesSent       syncint64.Counter
+	batches         syncint64.Counter
+	batchesFailed   syncint64.Counter
+}
+
+// newSelfTelemetry creates the instruments of the exporter. If mp is nil, nothing is recorded.
+func newSelfTelemetry(mp metric.MeterProvider) (*selfTelemetry, error) {
+	if mp == nil {
+		mp = metric.NewNoopMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	ints, floats := meter.SyncInt64(), meter.SyncFloat64()
+
+	var errs error
+	counter := func(name, description string, u unit.Unit) syncint64.Counter {
+		c, err := ints.Counter(name, instrument.WithDescription(description), instrument.WithUnit(u))
+		errs = multierr.Append(errs, err)
+		return c
+	}
+
+	t := &selfTelemetry{
+		linesSerialized: counter("dynatrace.exporter.lines.serialized", "Lines serialized for export", unit.Dimensionless),
+		linesDropped:    counter("dynatrace.exporter.lines.dropped", "Lines dropped before export, by reason", unit.Dimensionless),
+		linesOk:         counter("dynatrace.exporter.lines.ok", "Lines accepted by the ingest API", unit.Dimensionless),
+		linesInvalid:    counter("dynatrace.exporter.lines.invalid", "Lines rejected by the ingest API", unit.Dimensionless),
+		valuesSanitized: counter("dynatrace.exporter.values.sanitized", "Invalid values dropped or clamped, by reason and action", unit.Dimensionless),
+		requests:        counter("dynatrace.exporter.requests", "Requests to the ingest API, by HTTP status code", unit.Dimensionless),
+		bytesSent:       counter("dynatrace.exporter.bytes.sent", "Payload bytes sent to the ingest API", unit.Bytes),
+		batches:         counter("dynatrace.exporter.batches", "Batches passed to the sink", unit.Dimensionless),
+		batchesFailed:   counter("dynatrace.exporter.batches.failed", "Batches the sink failed to send", unit.Dimensionless),
+	}
+	var err error
+	t.requestDuration, err = floats.Histogram("dynatrace.exporter.request.duration",
+		instrument.WithDescription("Duration of requests to the ingest API"), instrument.WithUnit(unit.Milliseconds))
+	errs = multierr.Append(errs, err)
+
+	if errs != nil {
+		return nil, fmt.Errorf("error creating self-telemetry instruments: %s", errs.Error())
+	}
+	return t, nil
+}
+
+func (t *selfTelemetry) serialized(ctx context.Context, lines int) {
+	if t == nil {
+		return
+	}
+	t.linesSerialized.Add(ctx, int64(lines))
+}
+
+func (t *selfTelemetry) lineDropped(ctx context.Context, reason string) {
+	if t == nil {
+		return
+	}
+	t.linesDropped.Add(ctx, 1, reasonKey.String(reason))
+}
+
+func (t *selfTelemetry) valueSanitized(ctx context.Context, reason, action string) {
+	if t == nil {
+		return
+	}
+	t.valuesSanitized.Add(ctx, 1, reasonKey.String(reason), actionKey.String(action))
+}
+
+func (t *selfTelemetry) batch(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.batches.Add(ctx, 1)
+}
+
+// batchFailed records a batch the sink returned an error for,
+// including errors that happened before a response was received, such as timeouts.
+func (t *selfTelemetry) batchFailed(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.batchesFailed.Add(ctx, 1)
+}
+
+// request records a request to the ingest API that was answered with the given status code.
+func (t *selfTelemetry) request(ctx context.Context, statusCode int, duration time.Duration, bytes int) {
+	if t == nil {
+		return
+	}
+	t.requests.Add(ctx, 1, statusCodeKey.Int(statusCode))
+	t.requestDuration.Record(ctx, float64(duration)/float64(time.Millisecond), statusCodeKey.Int(statusCode))
+	t.bytesSent.Add(ctx, int64(bytes))
+}
+
+func (t *selfTelemetry) response(ctx context.Context, r metricsResponse) {
+	if t == nil {
+		return
+	}
+	t.linesOk.Add(ctx, r.Ok)
+	t.linesInvalid.Add(ctx, r.Invalid)
+}
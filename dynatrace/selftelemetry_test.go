@@ -0,0 +1,124 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestExporter_SelfTelemetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+		rw.Write([]byte(`{"linesOk": 2, "linesInvalid": 1, "error": null}`))
+	}))
+	defer server.Close()
+
+	mp, metrics := metrictest.NewTestMeterProvider()
+	e, err := NewExporter(Options{
+		URL:                                server.URL,
+		APIToken:                           "token",
+		DisableDynatraceMetadataEnrichment: true,
+		MeterProvider:                      mp,
+		FilterRules:                        []FilterRule{{Name: "debug.*", Action: FilterDrop}},
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			counterRecord(t, "requests", 1),
+			counterRecord(t, "errors", 2),
+			counterRecord(t, "debug.calls", 3),
+			counterRecord(t, "broken", math.NaN()),
+			aggregationRecord("sketch", number.Float64Kind, testUnsupported{}),
+		},
+	})
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	require.NoError(t, metrics.Collect(context.Background()))
+
+	sum := func(name string, attrs ...attribute.KeyValue) int64 {
+		t.Helper()
+		record, err := metrics.GetByNameAndAttributes(name, attrs)
+		require.NoError(t, err, name)
+		return record.Sum.AsInt64()
+	}
+
+	require.Equal(t, int64(2), sum("dynatrace.exporter.lines.serialized"))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.lines.dropped", reasonKey.String(DroppedFiltered)))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.lines.dropped", reasonKey.String(SanitizedNaN)))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.lines.dropped", reasonKey.String(DroppedUnsupportedAggregation)))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.values.sanitized", reasonKey.String(SanitizedNaN), actionKey.String("dropped")))
+	require.Equal(t, int64(2), sum("dynatrace.exporter.lines.ok"))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.lines.invalid"))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.requests", statusCodeKey.Int(http.StatusAccepted)))
+	require.Equal(t, int64(1), sum("dynatrace.exporter.batches"))
+	_, err = metrics.GetByNameAndAttributes("dynatrace.exporter.batches.failed", nil)
+	require.Error(t, err, "no batch failed")
+	require.Greater(t, sum("dynatrace.exporter.bytes.sent"), int64(0))
+
+	duration, err := metrics.GetByNameAndAttributes("dynatrace.exporter.request.duration", []attribute.KeyValue{statusCodeKey.Int(http.StatusAccepted)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), duration.Count)
+}
+
+func TestExporter_SelfTelemetry_TransportError(t *testing.T) {
+	mp, metrics := metrictest.NewTestMeterProvider()
+	e, err := NewExporter(Options{
+		URL:                                unreachableURL(),
+		APIToken:                           "token",
+		DisableDynatraceMetadataEnrichment: true,
+		MeterProvider:                      mp,
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {counterRecord(t, "requests", 1)},
+	})
+	require.Error(t, e.Export(context.Background(), resource.Empty(), reader))
+	require.NoError(t, metrics.Collect(context.Background()))
+
+	for _, name := range []string{"dynatrace.exporter.batches", "dynatrace.exporter.batches.failed"} {
+		record, err := metrics.GetByNameAndAttributes(name, nil)
+		require.NoError(t, err, name)
+		require.Equal(t, int64(1), record.Sum.AsInt64(), name)
+	}
+	_, err = metrics.GetByNameAndAttributes("dynatrace.exporter.requests", nil)
+	require.Error(t, err, "no response was received")
+}
+
+func TestSelfTelemetry_nil(t *testing.T) {
+	var telemetry *selfTelemetry
+	require.NotPanics(t, func() {
+		telemetry.serialized(context.Background(), 1)
+		telemetry.lineDropped(context.Background(), DroppedExpired)
+		telemetry.request(context.Background(), http.StatusOK, 0, 1)
+		telemetry.response(context.Background(), metricsResponse{Ok: 1})
+		telemetry.batchFailed(context.Background())
+	})
+}
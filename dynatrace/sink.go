@@ -0,0 +1,298 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// Sink delivers batches of serialized Dynatrace metric lines.
+// The payload passed to Send contains one line per metric, separated by newlines,
+// and never exceeds the number of lines accepted by the ingest API in a single request.
+//
+// Sinks that hold resources may additionally implement io.Closer,
+// in which case they are closed together with the exporter.
+type Sink interface {
+	Send(ctx context.Context, payload string) error
+}
+
+// sendFunc adapts a function to the Sink interface.
+type sendFunc func(ctx context.Context, payload string) error
+
+func (f sendFunc) Send(ctx context.Context, payload string) error {
+	return f(ctx, payload)
+}
+
+// defaultSinkClient is used by HTTPSinks without a client.
+var defaultSinkClient = &http.Client{Transport: newExporterTransport()}
+
+// HTTPSink posts payloads to the Dynatrace ingest API, like the exporter does when no Sink is configured.
+// NewHTTPSink creates one for combining the ingest API with other sinks.
+// Unlike the exporter, it records no self-telemetry and does not correct clock skew.
+type HTTPSink struct {
+	client      *http.Client
+	url         string
+	apiToken    string
+	compression Compression
+}
+
+// NewHTTPSink creates a sink that posts payloads to the ingest API at url, authenticated with apiToken
+// and compressed as configured. Request timeouts are taken from client.
+// If client is nil, a client without timeout is used.
+func NewHTTPSink(client *http.Client, url, apiToken string, compression Compression) *HTTPSink {
+	return &HTTPSink{client: client, url: url, apiToken: apiToken, compression: compression}
+}
+
+// Send posts the payload to the ingest API.
+func (s *HTTPSink) Send(ctx context.Context, payload string) error {
+	if s.url == "" {
+		return errors.New("no ingest URL configured")
+	}
+	client := s.client
+	if client == nil {
+		client = defaultSinkClient
+	}
+
+	resp, _, err := postLines(ctx, client, s.url, s.apiToken, s.compression, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return handleIngestResponse(ctx, resp, NewNopLogger(), nil)
+}
+
+// postLines posts the payload to the ingest API and returns the response together with the size of the request body.
+// The caller has to close the body of the response.
+func postLines(ctx context.Context, client *http.Client, url, apiToken string, compression Compression, payload string) (*http.Response, int, error) {
+	body := bytes.NewBufferString(payload)
+	if compression == GzipCompression {
+		compressed := &bytes.Buffer{}
+		zw := gzip.NewWriter(compressed)
+		if _, err := zw.Write(body.Bytes()); err != nil {
+			return nil, 0, fmt.Errorf("error compressing payload: %s", err.Error())
+		}
+		if err := zw.Close(); err != nil {
+			return nil, 0, fmt.Errorf("error compressing payload: %s", err.Error())
+		}
+		body = compressed
+	}
+
+	size := body.Len()
+	req, err := http.NewRequestWithContext(suppressInstrumentation(ctx), "POST", url, body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("dynatrace error while creating HTTP request: %s", err.Error())
+	}
+
+	req.Header.Add("Content-Type", "text/plain; charset=UTF-8")
+	if compression == GzipCompression {
+		req.Header.Add("Content-Encoding", "gzip")
+	}
+	req.Header.Add("Authorization", "Api-Token "+apiToken)
+	req.Header.Add("User-Agent", "opentelemetry-metric-go")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error sending HTTP request: %s", err.Error())
+	}
+	return resp, size, nil
+}
+
+// handleIngestResponse reads the response of the ingest API, logs and records the reported line counts
+// and returns an error if the request was not accepted.
+func handleIngestResponse(ctx context.Context, resp *http.Response, logger Logger, telemetry *selfTelemetry) error {
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error while receiving HTTP response: %s", err.Error())
+	}
+
+	responseBody := metricsResponse{}
+	if err := json.Unmarshal(bodyBytes, &responseBody); err != nil {
+		logger.Error("failed to unmarshal response", "error", err)
+	} else {
+		telemetry.response(ctx, responseBody)
+		logger.Debug("exported lines to Dynatrace", "count", responseBody.Ok)
+
+		if responseBody.Invalid > 0 {
+			logger.Debug("failed to export lines to Dynatrace", "count", responseBody.Invalid)
+		}
+
+		if responseBody.Error != nil && responseBody.Error.Message != "" {
+			logger.Error("error from Dynatrace", "message", responseBody.Error.Message)
+		}
+	}
+
+	if !(resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted) {
+		return fmt.Errorf("request failed with response code:, %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// WriterSink writes each payload followed by a newline to an io.Writer.
+// It is safe for concurrent use.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink that writes lines to w, for example os.Stdout or a bytes.Buffer.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Send writes the payload to the underlying writer.
+func (s *WriterSink) Send(_ context.Context, payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := io.WriteString(s.w, payload+"\n"); err != nil {
+		return fmt.Errorf("error writing lines: %s", err.Error())
+	}
+	return nil
+}
+
+// RotatingFileSink appends lines to a file and rotates it once it would grow beyond a size limit.
+// Rotated files are renamed to <path>.1, <path>.2, ... with <path>.1 being the most recent.
+// It is safe for concurrent use.
+type RotatingFileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFileSink opens or creates the file at path for appending.
+// The file is rotated before it would exceed maxBytes, and at most maxBackups rotated files are kept.
+func NewRotatingFileSink(path string, maxBytes int64, maxBackups int) (*RotatingFileSink, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("maxBytes must be positive")
+	}
+	if maxBackups < 0 {
+		return nil, errors.New("maxBackups must not be negative")
+	}
+
+	s := &RotatingFileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Send appends the payload to the current file, rotating it first if necessary.
+func (s *RotatingFileSink) Send(_ context.Context, payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("sink is closed")
+	}
+
+	data := payload + "\n"
+	if s.size > 0 && s.size+int64(len(data)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.WriteString(data)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing lines to %s: %s", s.path, err.Error())
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (s *RotatingFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *RotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %s", s.path, err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening %s: %s", s.path, err.Error())
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *RotatingFileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return s.reopen(fmt.Errorf("error rotating %s: %s", s.path, err.Error()))
+	}
+
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return s.reopen(fmt.Errorf("error rotating %s: %s", s.path, err.Error()))
+		}
+		return s.open()
+	}
+
+	// shift path.N-1 to path.N, dropping the oldest backup
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backupName(i), s.backupName(i+1)); err != nil && !os.IsNotExist(err) {
+			return s.reopen(fmt.Errorf("error rotating %s: %s", s.path, err.Error()))
+		}
+	}
+	if err := os.Rename(s.path, s.backupName(1)); err != nil {
+		return s.reopen(fmt.Errorf("error rotating %s: %s", s.path, err.Error()))
+	}
+
+	return s.open()
+}
+
+// reopen opens the current file again after a failed rotation, so that later sends keep appending to it
+// and retry the rotation. It returns the error of the rotation, or of reopening if that fails as well.
+func (s *RotatingFileSink) reopen(rotateErr error) error {
+	if err := s.open(); err != nil {
+		return multierr.Append(rotateErr, err)
+	}
+	return rotateErr
+}
+
+func (s *RotatingFileSink) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestExporter_Export_WriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e, err := NewExporter(Options{
+		Sink:                               NewWriterSink(buf),
+		DisableDynatraceMetadataEnrichment: true,
+	})
+	require.NoError(t, err)
+
+	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(11), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{
+			Name: "mylib",
+		}: {export.NewRecord(&desc, attribute.EmptySet(), ckpt.Aggregation(), intervalStart, intervalEnd)},
+	})
+
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	require.Equal(t, "name,dt.metrics.source=opentelemetry count,delta=11 "+intervalEndMillis+"\n", buf.String())
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.txt")
+	s, err := NewRotatingFileSink(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, payload := range []string{"line one", "line two", "line three", "line four"} {
+		require.NoError(t, s.Send(context.Background(), payload))
+	}
+	require.NoError(t, s.Close())
+
+	for file, expect := range map[string]string{
+		path:        "line four\n",
+		path + ".1": "line three\n",
+		path + ".2": "line two\n",
+	} {
+		content, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t, expect, string(content))
+	}
+
+	require.NoFileExists(t, path+".3")
+	require.Error(t, s.Send(context.Background(), "closed"))
+}
+
+func TestRotatingFileSink_RotationError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.txt")
+	s, err := NewRotatingFileSink(path, 10, 1)
+	require.NoError(t, err)
+	defer s.Close()
+
+	// A non-empty directory in place of the backup cannot be replaced by the rotated file.
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755))
+
+	require.NoError(t, s.Send(context.Background(), "line one"))
+	require.Error(t, s.Send(context.Background(), "line two"))
+
+	require.NoError(t, os.RemoveAll(path+".1"))
+	require.NoError(t, s.Send(context.Background(), "line three"))
+
+	for file, expect := range map[string]string{
+		path:        "line three\n",
+		path + ".1": "line one\n",
+	} {
+		content, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t, expect, string(content))
+	}
+}
+
+// teeSink sends each payload to all sinks.
+type teeSink []Sink
+
+func (t teeSink) Send(ctx context.Context, payload string) error {
+	for _, s := range t {
+		if err := s.Send(ctx, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "Api-Token token", req.Header.Get("Authorization"))
+		require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		received = string(body)
+		_, _ = rw.Write([]byte(`{"linesOk": 1, "linesInvalid": 0, "error": null}`))
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	e, err := NewExporter(Options{
+		Sink:                               teeSink{NewWriterSink(buf), NewHTTPSink(nil, server.URL, "token", GzipCompression)},
+		DisableDynatraceMetadataEnrichment: true,
+	})
+	require.NoError(t, err)
+
+	desc := metrictest.NewDescriptor("name", sdkapi.CounterInstrumentKind, number.Float64Kind)
+	sums := sum.New(2)
+	agg, ckpt := &sums[0], &sums[1]
+
+	require.NoError(t, agg.Update(context.Background(), number.NewFloat64Number(11), &desc))
+	require.NoError(t, agg.SynchronizedMove(ckpt, &desc))
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{
+			Name: "mylib",
+		}: {export.NewRecord(&desc, attribute.EmptySet(), ckpt.Aggregation(), intervalStart, intervalEnd)},
+	})
+
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	expected := "name,dt.metrics.source=opentelemetry count,delta=11 " + intervalEndMillis
+	require.Equal(t, expected+"\n", buf.String())
+	require.Equal(t, expected, received)
+}
+
+func TestHTTPSink_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := NewHTTPSink(server.Client(), server.URL, "token", NoCompression).Send(context.Background(), "name 1")
+	require.Error(t, err)
+
+	err = (&HTTPSink{}).Send(context.Background(), "name 1")
+	require.EqualError(t, err, "no ingest URL configured")
+}
+
+func TestHTTPSink_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	err := NewHTTPSink(client, server.URL, "token", NoCompression).Send(context.Background(), "name 1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error sending HTTP request")
+}
@@ -1,20 +1,173 @@
 package dynatrace
 
 import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"go.opentelemetry.io/otel/sdk/metric/export"
 	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
 	"go.opentelemetry.io/otel/sdk/metric/number"
 )
 
-func valueOptForSum(sum aggregation.Sum, monotonic bool, kind number.Kind) (metric.MetricOption, error) {
+// UpDownSumMode selects how non-monotonic sums, such as those of UpDownCounters, are exported.
+type UpDownSumMode int
+
+const (
+	// UpDownSumAsGauge exports the level of non-monotonic sums as gauge lines.
+	// Cumulative sums are requested, and delta sums handed over by a reader are accumulated per series.
+	UpDownSumAsGauge UpDownSumMode = iota
+	// UpDownSumAsDelta exports the change of non-monotonic sums as count,delta lines.
+	// Delta sums are requested, and cumulative sums handed over by a reader are differenced per series.
+	UpDownSumAsDelta
+)
+
+// upDownSeriesRetention is the number of exports after which a series without records is forgotten.
+const upDownSeriesRetention = 10
+
+// upDownSeries is the last state of a series of a non-monotonic sum.
+type upDownSeries struct {
+	start      time.Time
+	end        time.Time
+	level      number.Number
+	collection uint64
+}
+
+// upDownSums tracks the level of non-monotonic sums per series,
+// so they can be exported independent of the temporality they are handed over in.
+type upDownSums struct {
+	mu     sync.Mutex
+	series map[string]upDownSeries
+	// collections counts the finished exports.
+	collections uint64
+	// collected is the end of the records of the previous export, pending the end of the current one.
+	collected time.Time
+	pending   time.Time
+}
+
+// update records a sum and returns the level and the change of its series.
+// The temporality of the record is derived from its start time: a record that starts where the previous one
+// of its series ended is a delta, a record that shares the start time of the previous one is cumulative.
+func (u *upDownSums) update(key string, record export.Record, value number.Number, kind number.Kind) (level, delta number.Number) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.updateLocked(key, record, value, kind)
+}
+
+func (u *upDownSums) updateLocked(key string, record export.Record, value number.Number, kind number.Kind) (level, delta number.Number) {
+	if u.series == nil {
+		u.series = map[string]upDownSeries{}
+	}
+
+	start, end := record.StartTime(), record.EndTime()
+	u.observe(end)
+	prev, ok := u.series[key]
+	switch {
+	case !ok:
+		// Either way, the first record covers everything since the series started.
+		level, delta = value, value
+	case start.Equal(prev.start):
+		// Cumulative, the series continues.
+		level, delta = value, subtractNumbers(kind, value, prev.level)
+	case !start.Before(prev.end):
+		// Delta, possibly after intervals without updates.
+		level, delta = addNumbers(kind, prev.level, value), value
+	default:
+		// Cumulative, the series was reset.
+		level, delta = value, value
+	}
+
+	u.series[key] = upDownSeries{start: start, end: end, level: level, collection: u.collections}
+	return level, delta
+}
+
+// level records a sum and returns the level of its series.
+// Only delta records need the state of their series: a record that starts before the end of the previous export
+// is cumulative and its value is the level, so its series is not tracked.
+func (u *upDownSums) level(key string, record export.Record, value number.Number, kind number.Kind) number.Number {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if record.StartTime().Before(u.collected) {
+		u.observe(record.EndTime())
+		delete(u.series, key)
+		return value
+	}
+	level, _ := u.updateLocked(key, record, value, kind)
+	return level
+}
+
+// observe notes the end of a record of the current export.
+func (u *upDownSums) observe(end time.Time) {
+	if end.After(u.pending) {
+		u.pending = end
+	}
+}
+
+// finishCollection is called after every export.
+// It forgets series that had no records in the last upDownSeriesRetention exports.
+func (u *upDownSums) finishCollection() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.collected = u.pending
+	u.collections++
+	for key, series := range u.series {
+		if u.collections-series.collection > upDownSeriesRetention {
+			delete(u.series, key)
+		}
+	}
+}
+
+// seriesKey identifies a series by its metric name and dimensions, independent of the order of the dimensions.
+func seriesKey(name string, dims dimensions.NormalizedDimensionList) string {
+	return dims.Format(func(ds []dimensions.Dimension) string {
+		pairs := make([]string, 0, len(ds)+1)
+		for _, d := range ds {
+			pairs = append(pairs, d.Key+"="+d.Value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(append([]string{name}, pairs...), ",")
+	})
+}
+
+// valueOptForSum returns the value of a sum, or nil if the line has to be dropped.
+func (e *Exporter) valueOptForSum(name string, sum aggregation.Sum, monotonic bool, kind number.Kind, factor float64) (metric.MetricOption, error) {
 	value, err := sum.Sum()
 	if err != nil {
 		return nil, err
 	}
+	value, ok := e.sanitizeValue(name, value, kind, monotonic)
+	if !ok {
+		return nil, nil
+	}
 
 	if monotonic {
-		return metric.WithFloatCounterValueDelta(value.CoerceToFloat64(kind)), nil
+		return counterValue(value, kind, factor), nil
+	}
+
+	return gaugeValue(value, kind, factor), nil
+}
+
+// valueOptForUpDownSum returns the value of a non-monotonic sum according to the configured UpDownSumMode,
+// or nil if the line has to be dropped.
+func (e *Exporter) valueOptForUpDownSum(name string, record export.Record, sum aggregation.Sum, key string, factor float64) (metric.MetricOption, error) {
+	value, err := sum.Sum()
+	if err != nil {
+		return nil, err
 	}
 
-	return metric.WithFloatGaugeValue(value.CoerceToFloat64(kind)), nil
+	kind := record.Descriptor().NumberKind()
+	value, ok := e.sanitizeValue(name, value, kind, false)
+	if !ok {
+		return nil, nil
+	}
+	if e.opts.UpDownSumMode == UpDownSumAsDelta {
+		_, delta := e.upDownSums.update(key, record, value, kind)
+		return counterValue(delta, kind, factor), nil
+	}
+	return gaugeValue(e.upDownSums.level(key, record, value, kind), kind, factor), nil
 }
@@ -0,0 +1,183 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// upDownRecord returns a record of an int up-down counter covering the given interval.
+func upDownRecord(t *testing.T, value int64, start, end time.Time) export.Record {
+	t.Helper()
+	desc := metrictest.NewDescriptor("queue", sdkapi.UpDownCounterInstrumentKind, number.Int64Kind)
+	sums := sum.New(2)
+	require.NoError(t, sums[0].Update(context.Background(), number.NewInt64Number(value), &desc))
+	require.NoError(t, sums[0].SynchronizedMove(&sums[1], &desc))
+	set := attribute.NewSet(attribute.String("queue", "jobs"))
+	return export.NewRecord(&desc, &set, sums[1].Aggregation(), start, end)
+}
+
+func TestExporter_Export_UpDownSumMode(t *testing.T) {
+	t1 := intervalStart.Add(time.Minute)
+	t2 := t1.Add(time.Minute)
+	t3 := t2.Add(time.Minute)
+	ms := func(ts time.Time) string { return strconv.FormatInt(ts.UnixMilli(), 10) }
+
+	cumulative := []export.Record{
+		upDownRecord(t, 5, intervalStart, t1),
+		upDownRecord(t, 3, intervalStart, t2),
+		upDownRecord(t, 4, intervalStart, t3),
+	}
+	delta := []export.Record{
+		upDownRecord(t, 5, intervalStart, t1),
+		upDownRecord(t, -2, t1, t2),
+		// No updates between t2 and t3.
+		upDownRecord(t, 1, t3, t3.Add(time.Minute)),
+	}
+
+	run := func(t *testing.T, mode UpDownSumMode, records []export.Record) []string {
+		lines := []string{}
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DryRunHandler:                      func(r DryRunResult) { lines = append(lines, r.Valid...) },
+			DisableDynatraceMetadataEnrichment: true,
+			UpDownSumMode:                      mode,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		for _, record := range records {
+			reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+				{Name: "lib"}: {record},
+			})
+			require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+		}
+		return sortDimensionsOfLines(lines)
+	}
+
+	t.Run("cumulative sums as gauges", func(t *testing.T) {
+		require.Equal(t, []string{
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,5 " + ms(t1),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,3 " + ms(t2),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,4 " + ms(t3),
+		}, run(t, UpDownSumAsGauge, cumulative))
+	})
+
+	t.Run("delta sums are accumulated into gauges", func(t *testing.T) {
+		require.Equal(t, []string{
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,5 " + ms(t1),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,3 " + ms(t2),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,4 " + ms(t3.Add(time.Minute)),
+		}, run(t, UpDownSumAsGauge, delta))
+	})
+
+	t.Run("delta sums as deltas", func(t *testing.T) {
+		require.Equal(t, []string{
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=5 " + ms(t1),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=-2 " + ms(t2),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=1 " + ms(t3.Add(time.Minute)),
+		}, run(t, UpDownSumAsDelta, delta))
+	})
+
+	t.Run("cumulative sums are differenced into deltas", func(t *testing.T) {
+		require.Equal(t, []string{
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=5 " + ms(t1),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=-2 " + ms(t2),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=1 " + ms(t3),
+		}, run(t, UpDownSumAsDelta, cumulative))
+	})
+
+	t.Run("cumulative reset", func(t *testing.T) {
+		require.Equal(t, []string{
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=5 " + ms(t1),
+			"queue,dt.metrics.source=opentelemetry,queue=jobs count,delta=2 " + ms(t3),
+		}, run(t, UpDownSumAsDelta, []export.Record{
+			upDownRecord(t, 5, intervalStart, t1),
+			upDownRecord(t, 2, t1.Add(-time.Second), t3),
+		}))
+	})
+}
+
+func TestExporter_TemporalityFor_UpDownSumMode(t *testing.T) {
+	desc := metrictest.NewDescriptor("queue", sdkapi.UpDownCounterObserverInstrumentKind, number.Int64Kind)
+
+	e := &Exporter{}
+	require.Equal(t, aggregation.CumulativeTemporality, e.TemporalityFor(&desc, aggregation.SumKind))
+
+	e = &Exporter{opts: Options{UpDownSumMode: UpDownSumAsDelta}}
+	require.Equal(t, aggregation.DeltaTemporality, e.TemporalityFor(&desc, aggregation.SumKind))
+}
+
+func TestExporter_Export_UpDownSumState(t *testing.T) {
+	exportRecords := func(t *testing.T, e *Exporter, records ...export.Record) {
+		reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: records,
+		})
+		require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+	}
+	newExporter := func(t *testing.T, mode UpDownSumMode) *Exporter {
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DryRunHandler:                      func(DryRunResult) {},
+			DisableDynatraceMetadataEnrichment: true,
+			UpDownSumMode:                      mode,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = e.Close() })
+		return e
+	}
+	t1 := intervalStart.Add(time.Minute)
+	t2 := t1.Add(time.Minute)
+
+	t.Run("cumulative sums are not tracked as gauges", func(t *testing.T) {
+		e := newExporter(t, UpDownSumAsGauge)
+		exportRecords(t, e, upDownRecord(t, 5, intervalStart, t1))
+		exportRecords(t, e, upDownRecord(t, 3, intervalStart, t2))
+		require.Empty(t, e.upDownSums.series)
+	})
+
+	t.Run("delta sums are tracked as gauges", func(t *testing.T) {
+		e := newExporter(t, UpDownSumAsGauge)
+		exportRecords(t, e, upDownRecord(t, 5, intervalStart, t1))
+		exportRecords(t, e, upDownRecord(t, -2, t1, t2))
+		require.Len(t, e.upDownSums.series, 1)
+	})
+
+	t.Run("series without records are forgotten", func(t *testing.T) {
+		e := newExporter(t, UpDownSumAsDelta)
+		exportRecords(t, e, upDownRecord(t, 5, intervalStart, t1))
+		for i := 1; i < upDownSeriesRetention; i++ {
+			exportRecords(t, e)
+		}
+		require.Len(t, e.upDownSums.series, 1)
+		exportRecords(t, e)
+		require.Empty(t, e.upDownSums.series)
+	})
+}
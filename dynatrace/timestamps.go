@@ -0,0 +1,38 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import "time"
+
+// IngestAcceptanceWindow is how far in the past the ingest API accepts timestamps.
+// Older lines are rejected.
+const IngestAcceptanceWindow = time.Hour
+
+// currentTime returns the current time of the exporter's clock.
+func (e *Exporter) currentTime() time.Time {
+	if e.now != nil {
+		return e.now()
+	}
+	return time.Now()
+}
+
+// expired reports whether a line with the given timestamp should be dropped
+// because it would be rejected for being older than the acceptance window.
+func (e *Exporter) expired(ts time.Time) bool {
+	if !e.opts.DropExpiredLines || ts.IsZero() {
+		return false
+	}
+	return e.currentTime().Sub(ts) > IngestAcceptanceWindow
+}
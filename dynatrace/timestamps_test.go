@@ -0,0 +1,75 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExporter_Export_DropExpiredLines(t *testing.T) {
+	run := func(t *testing.T, drop bool, now time.Time) ([]string, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.WarnLevel)
+		lines := []string{}
+		e, err := NewExporter(Options{
+			DryRun:                             true,
+			DryRunHandler:                      func(r DryRunResult) { lines = append(lines, r.Valid...) },
+			DisableDynatraceMetadataEnrichment: true,
+			Logger:                             zap.New(core),
+			DropExpiredLines:                   drop,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+		e.now = func() time.Time { return now }
+
+		reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: {counterRecord(t, "requests", 1), gaugeRecord(t, "queue", 2)},
+		})
+		require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+		return lines, logs
+	}
+
+	t.Run("recent lines are kept", func(t *testing.T) {
+		lines, logs := run(t, true, intervalEnd.Add(IngestAcceptanceWindow))
+		require.Len(t, lines, 2)
+		require.Zero(t, logs.Len())
+	})
+
+	t.Run("expired lines are dropped", func(t *testing.T) {
+		lines, logs := run(t, true, intervalEnd.Add(IngestAcceptanceWindow+time.Minute))
+		require.Empty(t, lines)
+		require.Equal(t, 1, logs.FilterMessage("dropped lines older than the ingest acceptance window").Len())
+		require.Equal(t, int64(2), logs.All()[0].ContextMap()["count"])
+	})
+
+	t.Run("expired lines are kept by default", func(t *testing.T) {
+		lines, logs := run(t, false, intervalEnd.Add(24*time.Hour))
+		require.ElementsMatch(t, []string{
+			"requests,dt.metrics.source=opentelemetry count,delta=1 " + intervalEndMillis,
+			"queue,dt.metrics.source=opentelemetry gauge,2 " + intervalEndMillis,
+		}, lines)
+		require.Zero(t, logs.Len())
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	linesKey        = attribute.Key("dynatrace.lines")
+	batchesKey      = attribute.Key("dynatrace.batches")
+	payloadBytesKey = attribute.Key("dynatrace.payload.bytes")
+	contentLenKey   = attribute.Key("http.request_content_length")
+)
+
+// suppressInstrumentationKey marks the contexts of requests the exporter sends itself.
+type suppressInstrumentationKey struct{}
+
+// IsInstrumentationSuppressed reports whether the context belongs to a request the exporter sends to the ingest API.
+// HTTP instrumentation should skip such requests, for example using the filter option of otelhttp,
+// so that exporting does not create more telemetry to export.
+func IsInstrumentationSuppressed(ctx context.Context) bool {
+	suppressed, _ := ctx.Value(suppressInstrumentationKey{}).(bool)
+	return suppressed
+}
+
+func suppressInstrumentation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, suppressInstrumentationKey{}, true)
+}
+
+// newExporterTransport returns the transport for requests to the ingest API. It does not use http.DefaultTransport,
+// so instrumentation wrapped around the default transport does not trace the exporter's own requests.
+func newExporterTransport() http.RoundTripper {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return &http.Transport{Proxy: http.ProxyFromEnvironment}
+}
+
+// startSpan starts a span with the configured tracer provider.
+func (e *Exporter) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if e.tracer == nil {
+		return trace.NewNoopTracerProvider().Tracer(instrumentationName).Start(ctx, name, opts...)
+	}
+	return e.tracer.Start(ctx, name, opts...)
+}
+
+// sendBatch passes a batch of lines to the sink in a child span of the export.
+// Failed batches are not retried, so each span covers a single attempt and carries no retry count.
+// The context passed to the sink is marked, so that requests of custom sinks are not instrumented either.
+func (e *Exporter) sendBatch(ctx context.Context, payload string, lines int) error {
+	ctx, span := e.startSpan(suppressInstrumentation(ctx), "dynatrace.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(linesKey.Int(lines), payloadBytesKey.Int(len(payload))))
+	defer span.End()
+
+	e.telemetry.batch(ctx)
+	err := e.sink().Send(ctx, payload)
+	if err != nil {
+		e.telemetry.batchFailed(ctx)
+		recordSpanError(span, err)
+	}
+	return err
+}
+
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
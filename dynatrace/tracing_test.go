@@ -0,0 +1,156 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestExporter_Export_Tracing(t *testing.T) {
+	run := func(t *testing.T, status int) ([]sdktrace.ReadOnlySpan, error) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(status)
+			rw.Write([]byte(`{"linesOk": 2, "linesInvalid": 0, "error": null}`))
+		}))
+		defer server.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		e, err := NewExporter(Options{
+			URL:                                server.URL,
+			APIToken:                           "token",
+			DisableDynatraceMetadataEnrichment: true,
+			TracerProvider:                     sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: {counterRecord(t, "requests", 1), counterRecord(t, "errors", 2)},
+		})
+		err = e.Export(context.Background(), resource.Empty(), reader)
+		return recorder.Ended(), err
+	}
+
+	t.Run("span per export and batch", func(t *testing.T) {
+		spans, err := run(t, http.StatusAccepted)
+		require.NoError(t, err)
+		require.Len(t, spans, 2)
+
+		send, exp := spans[0], spans[1]
+		require.Equal(t, "dynatrace.send", send.Name())
+		require.Equal(t, "dynatrace.Export", exp.Name())
+		require.Equal(t, exp.SpanContext().SpanID(), send.Parent().SpanID())
+		require.Equal(t, instrumentationName, exp.InstrumentationLibrary().Name)
+
+		require.Equal(t, int64(2), spanAttributes(exp)[linesKey].AsInt64())
+		require.Equal(t, int64(1), spanAttributes(exp)[batchesKey].AsInt64())
+
+		attrs := spanAttributes(send)
+		require.Equal(t, int64(2), attrs[linesKey].AsInt64())
+		require.Greater(t, attrs[payloadBytesKey].AsInt64(), int64(0))
+		require.Equal(t, int64(http.StatusAccepted), attrs[statusCodeKey].AsInt64())
+		require.Equal(t, attrs[payloadBytesKey].AsInt64(), attrs[contentLenKey].AsInt64())
+		require.Equal(t, codes.Unset, send.Status().Code)
+	})
+
+	t.Run("failed requests are recorded", func(t *testing.T) {
+		spans, err := run(t, http.StatusBadRequest)
+		require.Error(t, err)
+		require.Len(t, spans, 2)
+		require.Equal(t, codes.Error, spans[0].Status().Code)
+		require.Equal(t, int64(http.StatusBadRequest), spanAttributes(spans[0])[statusCodeKey].AsInt64())
+		require.Equal(t, codes.Error, spans[1].Status().Code)
+	})
+}
+
+func TestExporter_send_SuppressesInstrumentation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"linesOk": 1, "linesInvalid": 0, "error": null}`))
+	}))
+	defer server.Close()
+
+	// Instrumentation wrapped around the default transport does not see the exporter's requests.
+	defaultTransport := http.DefaultTransport
+	defer func() { http.DefaultTransport = defaultTransport }()
+	instrumented := 0
+	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		instrumented++
+		return defaultTransport.RoundTrip(req)
+	})
+
+	e, err := NewExporter(Options{URL: server.URL, APIToken: "token", DisableDynatraceMetadataEnrichment: true})
+	require.NoError(t, err)
+	defer e.Close()
+
+	transport := e.client.Transport
+	suppressed := false
+	e.client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		suppressed = IsInstrumentationSuppressed(req.Context())
+		return transport.RoundTrip(req)
+	})
+
+	require.NoError(t, e.send(context.Background(), "requests count,delta=1"))
+	require.True(t, suppressed)
+	require.Zero(t, instrumented)
+	require.False(t, IsInstrumentationSuppressed(context.Background()))
+}
+
+func TestExporter_Export_SuppressesInstrumentationOfSinks(t *testing.T) {
+	suppressed := false
+	e, err := NewExporter(Options{
+		DisableDynatraceMetadataEnrichment: true,
+		Sink: sendFunc(func(ctx context.Context, payload string) error {
+			suppressed = IsInstrumentationSuppressed(ctx)
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {counterRecord(t, "requests", 1)},
+	})
+	ctx := context.Background()
+	require.NoError(t, e.Export(ctx, resource.Empty(), reader))
+	require.True(t, suppressed)
+	require.False(t, IsInstrumentationSuppressed(ctx))
+}
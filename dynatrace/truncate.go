@@ -0,0 +1,290 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/normalize"
+	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace/lineprotocol"
+)
+
+// TruncationStrategy decides what happens to a dimension key or value that exceeds its maximum length.
+type TruncationStrategy int
+
+const (
+	// Truncate cuts off the end of the key or value.
+	Truncate TruncationStrategy = iota
+	// TruncateWithHash replaces the end of the key or value with a hash of the complete key or value,
+	// so that different long values stay distinguishable.
+	TruncateWithHash
+	// DropDimension drops the whole dimension.
+	DropDimension
+)
+
+func (s TruncationStrategy) String() string {
+	switch s {
+	case Truncate:
+		return "truncate"
+	case TruncateWithHash:
+		return "truncate with hash"
+	case DropDimension:
+		return "drop dimension"
+	}
+	return fmt.Sprintf("TruncationStrategy(%d)", int(s))
+}
+
+// hashSuffixLength is the length of the suffix added by TruncateWithHash: an underscore and 8 hex digits.
+const hashSuffixLength = 9
+
+// DimensionLimits configures the maximum lengths of dimension keys and values.
+// Key lengths are measured in bytes before normalization, value lengths in bytes after escaping, since values
+// are cut to the maximum length of the ingest API once they are escaped. The zero value applies the limits
+// of the ingest API and truncates, which is what would otherwise happen silently during normalization.
+type DimensionLimits struct {
+	// MaxKeyLength defaults to and must not exceed the 100 characters accepted by the ingest API.
+	MaxKeyLength int
+	// MaxValueLength defaults to and must not exceed the 250 characters accepted by the ingest API.
+	MaxValueLength int
+	// KeyStrategy is applied to keys that are longer than MaxKeyLength.
+	KeyStrategy TruncationStrategy
+	// ValueStrategy is applied to values that are longer than MaxValueLength.
+	ValueStrategy TruncationStrategy
+}
+
+func (l DimensionLimits) withDefaults() DimensionLimits {
+	if l.MaxKeyLength == 0 {
+		l.MaxKeyLength = lineprotocol.MaxDimensionKeyLength
+	}
+	if l.MaxValueLength == 0 {
+		l.MaxValueLength = lineprotocol.MaxDimensionValueLength
+	}
+	return l
+}
+
+func (l DimensionLimits) validate() error {
+	l = l.withDefaults()
+	if l.MaxKeyLength < 0 || l.MaxKeyLength > lineprotocol.MaxDimensionKeyLength {
+		return fmt.Errorf("maximum dimension key length must be between 1 and %d, got %d", lineprotocol.MaxDimensionKeyLength, l.MaxKeyLength)
+	}
+	if l.MaxValueLength < 0 || l.MaxValueLength > lineprotocol.MaxDimensionValueLength {
+		return fmt.Errorf("maximum dimension value length must be between 1 and %d, got %d", lineprotocol.MaxDimensionValueLength, l.MaxValueLength)
+	}
+	for _, s := range []struct {
+		strategy  TruncationStrategy
+		maxLength int
+	}{{l.KeyStrategy, l.MaxKeyLength}, {l.ValueStrategy, l.MaxValueLength}} {
+		if s.strategy < Truncate || s.strategy > DropDimension {
+			return fmt.Errorf("unknown truncation strategy %d", s.strategy)
+		}
+		if s.strategy == TruncateWithHash && s.maxLength <= hashSuffixLength {
+			return fmt.Errorf("maximum length %d leaves no room for a hash suffix", s.maxLength)
+		}
+	}
+	return nil
+}
+
+// truncationCounter counts the dimensions that exceeded the limits, by original dimension key.
+type truncationCounter struct {
+	sync.Mutex
+	total   map[string]int64
+	pending map[string]int64
+}
+
+func (c *truncationCounter) add(key string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.total == nil {
+		c.total = map[string]int64{}
+		c.pending = map[string]int64{}
+	}
+	c.total[key]++
+	c.pending[key]++
+}
+
+// flush returns the counts since the last flush.
+func (c *truncationCounter) flush() map[string]int64 {
+	c.Lock()
+	defer c.Unlock()
+	pending := c.pending
+	c.pending = nil
+	if c.total != nil {
+		c.pending = map[string]int64{}
+	}
+	return pending
+}
+
+// TruncatedDimensions returns the number of dimensions that exceeded the configured length limits
+// since the exporter was created, by dimension key.
+func (e *Exporter) TruncatedDimensions() map[string]int64 {
+	e.truncations.Lock()
+	defer e.truncations.Unlock()
+	counts := make(map[string]int64, len(e.truncations.total))
+	for k, v := range e.truncations.total {
+		counts[k] = v
+	}
+	return counts
+}
+
+// dimensionList is a normalized dimension list together with the dimensions it was normalized from.
+// Dimensions are removed from the source dimensions, since normalized values would be escaped again
+// if they were normalized a second time.
+type dimensionList struct {
+	source     []dimensions.Dimension
+	normalized dimensions.NormalizedDimensionList
+}
+
+// normalizedDimensionList normalizes the dimensions as they are.
+func normalizedDimensionList(dims ...dimensions.Dimension) dimensionList {
+	return dimensionList{source: dims, normalized: dimensions.NewNormalizedDimensionList(dims...)}
+}
+
+// mergeDimensionLists merges the lists like dimensions.MergeLists, so later lists take precedence,
+// and keeps the source dimensions that end up in the merged list.
+func mergeDimensionLists(lists ...dimensionList) dimensionList {
+	normalized := make([]dimensions.NormalizedDimensionList, 0, len(lists))
+	index := map[string]int{}
+	source := []dimensions.Dimension{}
+	for _, list := range lists {
+		normalized = append(normalized, list.normalized)
+		for _, dim := range list.source {
+			key, err := normalize.DimensionKey(dim.Key)
+			if err != nil {
+				continue
+			}
+			if i, ok := index[key]; ok {
+				source[i] = dim
+			} else {
+				index[key] = len(source)
+				source = append(source, dim)
+			}
+		}
+	}
+	return dimensionList{source: source, normalized: dimensions.MergeLists(normalized...)}
+}
+
+// without returns the normalized list without the dimensions whose normalized keys are in keys.
+func (l dimensionList) without(keys map[string]bool) dimensions.NormalizedDimensionList {
+	if len(keys) == 0 {
+		return l.normalized
+	}
+	kept := make([]dimensions.Dimension, 0, len(l.source))
+	for _, dim := range l.source {
+		if key, err := normalize.DimensionKey(dim.Key); err == nil && !keys[key] {
+			kept = append(kept, dim)
+		}
+	}
+	return dimensions.NewNormalizedDimensionList(kept...)
+}
+
+// newDimensionList anonymizes the dimensions, applies the dimension limits and normalizes them.
+func (e *Exporter) newDimensionList(dims ...dimensions.Dimension) dimensionList {
+	limits := e.opts.DimensionLimits.withDefaults()
+	limited := make([]dimensions.Dimension, 0, len(dims))
+
+	for _, dim := range dims {
+		dim = e.anonymizer.anonymize(dim)
+		key, value := dim.Key, dim.Value
+		exceeded := false
+
+		if len(key) > limits.MaxKeyLength {
+			exceeded = true
+			if limits.KeyStrategy == DropDimension {
+				e.truncations.add(dim.Key)
+				continue
+			}
+			key = truncate(key, limits.MaxKeyLength, limits.KeyStrategy, cutAtRuneBoundary)
+		}
+		if escapedLength(value) > limits.MaxValueLength {
+			exceeded = true
+			if limits.ValueStrategy == DropDimension {
+				e.truncations.add(dim.Key)
+				continue
+			}
+			value = truncate(value, limits.MaxValueLength, limits.ValueStrategy, cutEscapedAtRuneBoundary)
+		}
+
+		if exceeded {
+			e.truncations.add(dim.Key)
+		}
+		limited = append(limited, dimensions.NewDimension(key, value))
+	}
+
+	return normalizedDimensionList(limited...)
+}
+
+// logTruncations logs the dimensions that exceeded the limits since the last call.
+func (e *Exporter) logTruncations() {
+	if counts := e.truncations.flush(); len(counts) > 0 {
+		e.logger.Warn("dimensions exceeded the length limits",
+			"counts", counts,
+			"keyStrategy", e.opts.DimensionLimits.KeyStrategy,
+			"valueStrategy", e.opts.DimensionLimits.ValueStrategy)
+	}
+}
+
+// truncate shortens s to maxLength as measured by cut, which returns the longest prefix of at most n.
+func truncate(s string, maxLength int, strategy TruncationStrategy, cut func(s string, n int) string) string {
+	if strategy != TruncateWithHash {
+		return cut(s, maxLength)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return fmt.Sprintf("%s_%08x", cut(s, maxLength-hashSuffixLength), h.Sum32())
+}
+
+// isEscaped reports whether the normalizer escapes the byte in dimension values.
+func isEscaped(c byte) bool {
+	return c == '=' || c == ' ' || c == ',' || c == '\\' || c == '"'
+}
+
+// escapedLength returns the length of the value after the normalizer escaped it.
+func escapedLength(value string) int {
+	n := len(value)
+	for i := 0; i < len(value); i++ {
+		if isEscaped(value[i]) {
+			n++
+		}
+	}
+	return n
+}
+
+// cutEscapedAtRuneBoundary returns the longest prefix of s that is at most n bytes long once it is escaped
+// and does not split a UTF-8 sequence.
+func cutEscapedAtRuneBoundary(s string, n int) string {
+	length := 0
+	for i := 0; i < len(s); i++ {
+		length++
+		if isEscaped(s[i]) {
+			length++
+		}
+		if length > n {
+			return cutAtRuneBoundary(s, i)
+		}
+	}
+	return s
+}
+
+// cutAtRuneBoundary returns the longest prefix of s that has at most n bytes and does not split a UTF-8 sequence.
+func cutAtRuneBoundary(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
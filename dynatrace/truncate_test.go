@@ -0,0 +1,150 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric/dimensions"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/processor/processortest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func formatDimensions(list dimensions.NormalizedDimensionList) []string {
+	return strings.Split(list.Format(func(dims []dimensions.Dimension) string {
+		s := []string{}
+		for _, d := range dims {
+			s = append(s, d.Key+"="+d.Value)
+		}
+		return strings.Join(s, "\n")
+	}), "\n")
+}
+
+func TestExporter_newDimensionList(t *testing.T) {
+	dims := []dimensions.Dimension{
+		NewDimension("path", "/api/users/1234567890"),
+		NewDimension("path.template", "/api/users/{id}"),
+		NewDimension("a.very.long.key", "value"),
+	}
+	newExporter := func(limits DimensionLimits) *Exporter {
+		e, err := NewExporter(Options{DryRun: true, DisableDynatraceMetadataEnrichment: true, DimensionLimits: limits})
+		require.NoError(t, err)
+		return e
+	}
+
+	t.Run("truncate", func(t *testing.T) {
+		e := newExporter(DimensionLimits{MaxKeyLength: 10, MaxValueLength: 15})
+		require.Equal(t, []string{"path=/api/users/1234", "path.templ=/api/users/{id}", "a.very.lon=value"}, formatDimensions(e.newDimensionList(dims...).normalized))
+		require.Equal(t, map[string]int64{"path": 1, "path.template": 1, "a.very.long.key": 1, "dt.metrics.source": 1}, e.TruncatedDimensions())
+	})
+
+	t.Run("hash suffix keeps values distinguishable", func(t *testing.T) {
+		e := newExporter(DimensionLimits{MaxValueLength: 15, ValueStrategy: TruncateWithHash})
+		first := formatDimensions(e.newDimensionList(NewDimension("path", "/api/users/1234567890")).normalized)
+		second := formatDimensions(e.newDimensionList(NewDimension("path", "/api/users/1234567891")).normalized)
+		require.Len(t, first[0], len("path=")+15)
+		require.True(t, strings.HasPrefix(first[0], "path=/api/u_"))
+		require.NotEqual(t, first, second)
+		require.Equal(t, map[string]int64{"path": 2}, e.TruncatedDimensions())
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		e := newExporter(DimensionLimits{MaxKeyLength: 10, KeyStrategy: DropDimension, MaxValueLength: 15, ValueStrategy: DropDimension})
+		require.Equal(t, []string{""}, formatDimensions(e.newDimensionList(dims[0]).normalized))
+		require.Equal(t, []string{"path=/api/users/{id}"}, formatDimensions(e.newDimensionList(NewDimension("path", "/api/users/{id}")).normalized))
+		require.Equal(t, map[string]int64{"path": 1, "dt.metrics.source": 1}, e.TruncatedDimensions())
+	})
+
+	t.Run("does not split UTF-8 sequences", func(t *testing.T) {
+		require.Equal(t, "ab", truncate("abü", 3, Truncate, cutAtRuneBoundary))
+		require.Equal(t, "abü", truncate("abüc", 4, Truncate, cutAtRuneBoundary))
+		require.Equal(t, "a b", truncate("a büc", 5, Truncate, cutEscapedAtRuneBoundary))
+	})
+
+	t.Run("values are measured after escaping", func(t *testing.T) {
+		e := newExporter(DimensionLimits{})
+		value := strings.Repeat(" ", 200)
+		require.Equal(t, []string{"spaces=" + strings.Repeat(`\ `, 125)}, formatDimensions(e.newDimensionList(NewDimension("spaces", value)).normalized))
+		require.Equal(t, map[string]int64{"spaces": 1}, e.TruncatedDimensions())
+
+		e = newExporter(DimensionLimits{MaxValueLength: 20, ValueStrategy: TruncateWithHash})
+		formatted := formatDimensions(e.newDimensionList(NewDimension("csv", "a,b,c,d,e,f,g,h")).normalized)
+		require.LessOrEqual(t, len(formatted[0]), len("csv=")+20)
+		require.True(t, strings.HasPrefix(formatted[0], `csv=a\,b\,c\,d_`), formatted[0])
+	})
+
+	t.Run("applies to enrichers and added dimensions", func(t *testing.T) {
+		lines := exportLines(t, Options{
+			DimensionLimits: DimensionLimits{MaxValueLength: 5},
+			Enrichers: []Enricher{EnricherFunc(func(context.Context) ([]dimensions.Dimension, error) {
+				return []dimensions.Dimension{NewDimension("enriched", "enricher value")}, nil
+			})},
+			FilterRules: []FilterRule{{Name: "*", AddDimensions: []dimensions.Dimension{NewDimension("added", "added value")}}},
+		}, resource.Empty(), map[instrumentation.Library][]export.Record{
+			{Name: "lib"}: {counterRecord(t, "requests", 1)},
+		})
+		require.Equal(t, []string{
+			"requests,added=added,dt.metrics.source=opent,enriched=enric count,delta=1 " + intervalEndMillis,
+		}, sortDimensionsOfLines(lines))
+	})
+}
+
+func TestExporter_Export_LogsTruncations(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	e, err := NewExporter(Options{
+		DryRun:                             true,
+		DryRunHandler:                      func(DryRunResult) {},
+		DisableDynatraceMetadataEnrichment: true,
+		Logger:                             zap.New(core),
+		DimensionLimits:                    DimensionLimits{MaxValueLength: 10},
+	})
+	require.NoError(t, err)
+
+	reader := processortest.MultiInstrumentationLibraryReader(map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			counterRecord(t, "requests", 1, attribute.String("url", "https://example.com/1")),
+			counterRecord(t, "requests", 1, attribute.String("url", "https://example.com/2")),
+		},
+	})
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), reader))
+
+	entries := logs.FilterMessage("dimensions exceeded the length limits").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, map[string]int64{"url": 2, "dt.metrics.source": 1}, entries[0].ContextMap()["counts"])
+
+	require.NoError(t, e.Export(context.Background(), resource.Empty(), processortest.MultiInstrumentationLibraryReader(nil)))
+	require.Len(t, logs.FilterMessage("dimensions exceeded the length limits").All(), 1, "nothing new to report")
+}
+
+func TestValidateOptions_DimensionLimits(t *testing.T) {
+	for _, limits := range []DimensionLimits{
+		{MaxKeyLength: 101},
+		{MaxValueLength: -1},
+		{MaxValueLength: 5, ValueStrategy: TruncateWithHash},
+		{KeyStrategy: TruncationStrategy(9)},
+	} {
+		_, err := NewExporter(Options{DryRun: true, DimensionLimits: limits})
+		require.Error(t, err, "%+v", limits)
+	}
+}
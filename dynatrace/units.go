@@ -0,0 +1,134 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/normalize"
+)
+
+// UnitOptions configures how the unit of an instrument is used.
+// By default, units are ignored.
+type UnitOptions struct {
+	// ConvertToCanonical converts values to the canonical unit of their dimension,
+	// for example milliseconds to seconds and kibibytes to bytes.
+	ConvertToCanonical bool
+	// AppendSuffix appends the name of the unit to the metric key, for example "_seconds" or "_bytes".
+	// If ConvertToCanonical is set, the name of the canonical unit is appended.
+	AppendSuffix bool
+	// SendMetadata sends a metadata line with the Dynatrace unit for each exported metric key.
+	SendMetadata bool
+}
+
+// unitInfo describes a UCUM unit.
+type unitInfo struct {
+	// dynatrace is the name of the unit in Dynatrace.
+	dynatrace string
+	// suffix is appended to metric keys.
+	suffix string
+	// canonical is the UCUM unit values are converted to, and factor the factor needed for the conversion.
+	canonical string
+	factor    float64
+}
+
+// ucumUnits contains the supported UCUM units. Units without a canonical unit are their own canonical unit.
+var ucumUnits = map[string]unitInfo{
+	"ns":  {dynatrace: "NanoSecond", suffix: "nanoseconds", canonical: "s", factor: 1e-9},
+	"us":  {dynatrace: "MicroSecond", suffix: "microseconds", canonical: "s", factor: 1e-6},
+	"ms":  {dynatrace: "MilliSecond", suffix: "milliseconds", canonical: "s", factor: 1e-3},
+	"s":   {dynatrace: "Second", suffix: "seconds"},
+	"min": {dynatrace: "Minute", suffix: "minutes", canonical: "s", factor: 60},
+	"h":   {dynatrace: "Hour", suffix: "hours", canonical: "s", factor: 3600},
+	"d":   {dynatrace: "Day", suffix: "days", canonical: "s", factor: 86400},
+
+	"By":   {dynatrace: "Byte", suffix: "bytes"},
+	"KBy":  {dynatrace: "KiloByte", suffix: "kilobytes", canonical: "By", factor: 1e3},
+	"MBy":  {dynatrace: "MegaByte", suffix: "megabytes", canonical: "By", factor: 1e6},
+	"GBy":  {dynatrace: "GigaByte", suffix: "gigabytes", canonical: "By", factor: 1e9},
+	"KiBy": {dynatrace: "KibiByte", suffix: "kibibytes", canonical: "By", factor: 1 << 10},
+	"MiBy": {dynatrace: "MebiByte", suffix: "mebibytes", canonical: "By", factor: 1 << 20},
+	"GiBy": {dynatrace: "GibiByte", suffix: "gibibytes", canonical: "By", factor: 1 << 30},
+
+	"bit":  {dynatrace: "Bit", suffix: "bits"},
+	"By/s": {dynatrace: "BytePerSecond", suffix: "bytes_per_second"},
+	"%":    {dynatrace: "Percent", suffix: "percent"},
+}
+
+// DynatraceUnit returns the name Dynatrace uses for the given UCUM unit.
+// It returns false if the unit is not known.
+func DynatraceUnit(ucum string) (string, bool) {
+	info, ok := ucumUnits[ucum]
+	return info.dynatrace, ok
+}
+
+// resolvedUnit describes how the values and key of an instrument with a given unit are exported.
+type resolvedUnit struct {
+	// factor converts recorded values to exported values.
+	factor float64
+	// suffix is appended to the metric key, if not empty.
+	suffix string
+	// dynatrace is the Dynatrace name of the exported unit, or empty if unknown.
+	dynatrace string
+}
+
+// resolveUnit looks up the unit of an instrument. Unknown units are exported unchanged.
+func (e *Exporter) resolveUnit(unit string) resolvedUnit {
+	resolved := resolvedUnit{factor: 1}
+	info, ok := ucumUnits[unit]
+	if !ok {
+		return resolved
+	}
+
+	if e.opts.Units.ConvertToCanonical && info.canonical != "" {
+		resolved.factor = info.factor
+		info = ucumUnits[info.canonical]
+	}
+	resolved.dynatrace = info.dynatrace
+	if e.opts.Units.AppendSuffix {
+		resolved.suffix = info.suffix
+	}
+	return resolved
+}
+
+// withUnitSuffix appends the unit suffix to the metric name unless it already ends with it.
+func (u resolvedUnit) withUnitSuffix(name string) string {
+	if u.suffix == "" || strings.HasSuffix(name, "_"+u.suffix) {
+		return name
+	}
+	return name + "_" + u.suffix
+}
+
+// unitMetadataLine returns the metadata line announcing the unit of a metric,
+// or an empty string if the unit is unknown or the key is invalid.
+func (e *Exporter) unitMetadataLine(name string, u resolvedUnit, count bool) string {
+	if u.dynatrace == "" {
+		return ""
+	}
+	key := name
+	if e.opts.Prefix != "" {
+		key = e.opts.Prefix + "." + name
+	}
+	key, err := normalize.MetricKey(key)
+	if err != nil {
+		return ""
+	}
+	typ := "gauge"
+	if count {
+		typ = "count"
+	}
+	return fmt.Sprintf("#%s %s dt.meta.unit=%s", key, typ, u.dynatrace)
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func unitRecords(t *testing.T) map[instrumentation.Library][]export.Record {
+	counterDesc := sdkapi.NewDescriptor("transferred", sdkapi.CounterInstrumentKind, number.Int64Kind, "", "KiBy")
+	sums := sum.New(2)
+	require.NoError(t, sums[0].Update(context.Background(), number.NewInt64Number(2), &counterDesc))
+	require.NoError(t, sums[0].SynchronizedMove(&sums[1], &counterDesc))
+
+	histDesc := sdkapi.NewDescriptor("duration", sdkapi.HistogramInstrumentKind, number.Float64Kind, "", unit.Milliseconds)
+	hists := histogram.New(2, &histDesc, histogram.WithExplicitBoundaries([]float64{100, 1000}))
+	require.NoError(t, hists[0].Update(context.Background(), number.NewFloat64Number(250), &histDesc))
+	require.NoError(t, hists[0].Update(context.Background(), number.NewFloat64Number(750), &histDesc))
+	require.NoError(t, hists[0].SynchronizedMove(&hists[1], &histDesc))
+
+	otherDesc := sdkapi.NewDescriptor("queue", sdkapi.UpDownCounterInstrumentKind, number.Float64Kind, "", "{items}")
+	others := sum.New(2)
+	require.NoError(t, others[0].Update(context.Background(), number.NewFloat64Number(3), &otherDesc))
+	require.NoError(t, others[0].SynchronizedMove(&others[1], &otherDesc))
+
+	return map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			export.NewRecord(&counterDesc, attribute.EmptySet(), sums[1].Aggregation(), intervalStart, intervalEnd),
+			export.NewRecord(&histDesc, attribute.EmptySet(), hists[1].Aggregation(), intervalStart, intervalEnd),
+			export.NewRecord(&otherDesc, attribute.EmptySet(), others[1].Aggregation(), intervalStart, intervalEnd),
+		},
+	}
+}
+
+func TestExporter_Export_Units(t *testing.T) {
+	t.Run("units are ignored by default", func(t *testing.T) {
+		lines := exportLines(t, Options{}, resource.Empty(), unitRecords(t))
+		require.ElementsMatch(t, []string{
+			"transferred,dt.metrics.source=opentelemetry count,delta=2 " + intervalEndMillis,
+			"duration,dt.metrics.source=opentelemetry gauge,min=100,max=1000,sum=1000,count=2 " + intervalEndMillis,
+			"queue,dt.metrics.source=opentelemetry gauge,3 " + intervalEndMillis,
+		}, lines)
+	})
+
+	t.Run("suffix without conversion", func(t *testing.T) {
+		lines := exportLines(t, Options{Units: UnitOptions{AppendSuffix: true}}, resource.Empty(), unitRecords(t))
+		require.ElementsMatch(t, []string{
+			"transferred_kibibytes,dt.metrics.source=opentelemetry count,delta=2 " + intervalEndMillis,
+			"duration_milliseconds,dt.metrics.source=opentelemetry gauge,min=100,max=1000,sum=1000,count=2 " + intervalEndMillis,
+			"queue,dt.metrics.source=opentelemetry gauge,3 " + intervalEndMillis,
+		}, lines)
+	})
+
+	t.Run("conversion to canonical units with suffix and metadata", func(t *testing.T) {
+		lines := exportLines(t, Options{
+			Prefix: "app",
+			Units:  UnitOptions{ConvertToCanonical: true, AppendSuffix: true, SendMetadata: true},
+		}, resource.Empty(), unitRecords(t))
+		require.ElementsMatch(t, []string{
+			"app.transferred_bytes,dt.metrics.source=opentelemetry count,delta=2048 " + intervalEndMillis,
+			"#app.transferred_bytes count dt.meta.unit=Byte",
+			"app.duration_seconds,dt.metrics.source=opentelemetry gauge,min=0.1,max=1,sum=1,count=2 " + intervalEndMillis,
+			"#app.duration_seconds gauge dt.meta.unit=Second",
+			"app.queue,dt.metrics.source=opentelemetry gauge,3 " + intervalEndMillis,
+		}, lines)
+	})
+}
+
+func TestDynatraceUnit(t *testing.T) {
+	u, ok := DynatraceUnit("ms")
+	require.True(t, ok)
+	require.Equal(t, "MilliSecond", u)
+
+	_, ok = DynatraceUnit("{requests}")
+	require.False(t, ok)
+}
+
+func Test_resolvedUnit_withUnitSuffix(t *testing.T) {
+	u := resolvedUnit{factor: 1, suffix: "seconds"}
+	require.Equal(t, "duration_seconds", u.withUnitSuffix("duration"))
+	require.Equal(t, "duration_seconds", u.withUnitSuffix("duration_seconds"))
+	require.Equal(t, "duration", resolvedUnit{factor: 1}.withUnitSuffix("duration"))
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/normalize"
+	"go.uber.org/multierr"
+)
+
+// maxPrefixLength leaves room for at least a short metric name in a key of
+// at most 250 characters, which is the limit of the ingest API.
+const maxPrefixLength = 200
+
+// validateOptions checks opts for misconfigurations that would otherwise only show up
+// as failed requests or silently rejected lines. All problems are returned together.
+// Configurations that work but are unsafe are logged as warnings.
+// Endpoint checks are skipped if a custom Sink is configured or in dry-run mode.
+func validateOptions(opts Options, logger Logger) error {
+	var errs error
+
+	if opts.Sink == nil && !opts.DryRun {
+		errs = multierr.Append(errs, validateEndpoint(opts, logger))
+	}
+
+	if opts.Prefix != "" {
+		if len(opts.Prefix) > maxPrefixLength {
+			errs = multierr.Append(errs, fmt.Errorf("prefix is longer than %d characters", maxPrefixLength))
+		} else if _, err := normalize.MetricKey(opts.Prefix); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("prefix %q cannot be used as a metric key: %s", opts.Prefix, err.Error()))
+		}
+	}
+
+	if opts.Compression != NoCompression && opts.Compression != GzipCompression {
+		errs = multierr.Append(errs, fmt.Errorf("unsupported compression %d", opts.Compression))
+	}
+
+	if opts.Timeout < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("timeout must not be negative, got %s", opts.Timeout))
+	}
+
+	if opts.UpDownSumMode != UpDownSumAsGauge && opts.UpDownSumMode != UpDownSumAsDelta {
+		errs = multierr.Append(errs, fmt.Errorf("unsupported up-down sum mode %d", opts.UpDownSumMode))
+	}
+
+	if opts.InvalidValues != DropInvalidValues && opts.InvalidValues != ClampInvalidValues {
+		errs = multierr.Append(errs, fmt.Errorf("unsupported invalid value policy %d", opts.InvalidValues))
+	}
+
+	if opts.ClockSkewLogThreshold < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("clock skew log threshold must not be negative, got %s", opts.ClockSkewLogThreshold))
+	}
+
+	if err := opts.DimensionLimits.validate(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if _, err := newAnonymizer(opts.Anonymization); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if _, err := compileFilterRules(opts.FilterRules); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	return errs
+}
+
+func validateEndpoint(opts Options, logger Logger) error {
+	u, err := url.ParseRequestURI(opts.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %s", opts.URL, err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", opts.URL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", opts.URL)
+	}
+
+	if isLocalhost(u.Hostname()) {
+		return nil
+	}
+	if opts.APIToken == "" {
+		return fmt.Errorf("an API token is required for the non-local endpoint %q", opts.URL)
+	}
+	if u.Scheme == "http" {
+		logger.Warn("API token will be sent unencrypted over plain HTTP",
+			"url", opts.URL)
+	}
+	return nil
+}
+
+// isLocalhost reports whether host refers to the local machine, which is where the OneAgent endpoint is served.
+func isLocalhost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
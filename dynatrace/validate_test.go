@@ -0,0 +1,69 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewExporter_Validation(t *testing.T) {
+	t.Run("accepts local OneAgent endpoint without token", func(t *testing.T) {
+		_, err := NewExporter(Options{URL: "http://127.0.0.1:14499/metrics/ingest"})
+		require.NoError(t, err)
+	})
+
+	t.Run("requires token for remote endpoint", func(t *testing.T) {
+		_, err := NewExporter(Options{URL: "https://example.com/api/v2/metrics/ingest"})
+		require.ErrorContains(t, err, "API token is required")
+	})
+
+	t.Run("rejects invalid URL", func(t *testing.T) {
+		_, err := NewExporter(Options{URL: "example.com/ingest"})
+		require.ErrorContains(t, err, "invalid URL")
+
+		_, err = NewExporter(Options{URL: "ftp://example.com/ingest", APIToken: "token"})
+		require.ErrorContains(t, err, "scheme must be http or https")
+	})
+
+	t.Run("rejects invalid prefix", func(t *testing.T) {
+		_, err := NewExporter(Options{Prefix: ".prefix"})
+		require.ErrorContains(t, err, "cannot be used as a metric key")
+	})
+
+	t.Run("reports all problems", func(t *testing.T) {
+		_, err := NewExporter(Options{URL: "https://example.com", Prefix: ".prefix", Compression: Compression(42), Timeout: -1})
+		require.Error(t, err)
+		require.Len(t, multierr.Errors(errors.Unwrap(err)), 4)
+	})
+
+	t.Run("warns about token over plain HTTP", func(t *testing.T) {
+		core, logs := observer.New(zapcore.WarnLevel)
+		_, err := NewExporter(Options{URL: "http://example.com/api/v2/metrics/ingest", APIToken: "token", Logger: zap.New(core)})
+		require.NoError(t, err)
+		require.Equal(t, 1, logs.FilterMessageSnippet("unencrypted").Len())
+
+		core, logs = observer.New(zapcore.WarnLevel)
+		_, err = NewExporter(Options{URL: "http://localhost:14499/metrics/ingest", APIToken: "token", Logger: zap.New(core)})
+		require.NoError(t, err)
+		require.Equal(t, 0, logs.Len())
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"math"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// exportsIntegers reports whether values of the given kind are serialized as integers.
+// Values of int64 instruments keep their full precision unless they are converted to another unit.
+func exportsIntegers(kind number.Kind, factor float64) bool {
+	return kind == number.Int64Kind && factor == 1
+}
+
+// scaled converts a value to the exported unit. Results beyond the range of float64 are clamped to it,
+// so that values clamped by sanitizeValue stay finite when they are converted to a smaller unit.
+func scaled(value number.Number, kind number.Kind, factor float64) float64 {
+	return math.Max(-math.MaxFloat64, math.Min(value.CoerceToFloat64(kind)*factor, math.MaxFloat64))
+}
+
+// counterValue returns the option for a count,delta value.
+func counterValue(value number.Number, kind number.Kind, factor float64) metric.MetricOption {
+	if exportsIntegers(kind, factor) {
+		return metric.WithIntCounterValueDelta(value.AsInt64())
+	}
+	return metric.WithFloatCounterValueDelta(scaled(value, kind, factor))
+}
+
+// gaugeValue returns the option for a gauge value.
+func gaugeValue(value number.Number, kind number.Kind, factor float64) metric.MetricOption {
+	if exportsIntegers(kind, factor) {
+		return metric.WithIntGaugeValue(value.AsInt64())
+	}
+	return metric.WithFloatGaugeValue(scaled(value, kind, factor))
+}
+
+// summaryValue returns the option for a summary value.
+func summaryValue(min, max, sum number.Number, count uint64, kind number.Kind, factor float64) metric.MetricOption {
+	if exportsIntegers(kind, factor) {
+		return metric.WithIntSummaryValue(min.AsInt64(), max.AsInt64(), sum.AsInt64(), int64(count))
+	}
+	return metric.WithFloatSummaryValue(
+		scaled(min, kind, factor),
+		scaled(max, kind, factor),
+		scaled(sum, kind, factor),
+		int64(count),
+	)
+}
+
+// integerNumber converts a float to an int64 number, if it is a whole number within the range of int64.
+func integerNumber(f float64) (number.Number, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return number.Number(0), false
+	}
+	return number.NewInt64Number(int64(f)), true
+}
+
+// subtractNumbers returns a - b.
+func subtractNumbers(kind number.Kind, a, b number.Number) number.Number {
+	if kind == number.Int64Kind {
+		return number.NewInt64Number(a.AsInt64() - b.AsInt64())
+	}
+	return number.NewFloat64Number(a.AsFloat64() - b.AsFloat64())
+}
+
+// addNumbers returns a + b.
+func addNumbers(kind number.Kind, a, b number.Number) number.Number {
+	a.AddNumber(kind, b)
+	return a
+}
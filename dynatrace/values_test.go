@@ -0,0 +1,111 @@
+// Copyright 2022 Dynatrace LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatrace
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-metric-utils-go/metric"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/sum"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// maxSafeInteger is the largest integer a float64 represents exactly, 2^53.
+const maxSafeInteger = 1 << 53
+
+func serializeValue(t *testing.T, value metric.MetricOption) string {
+	t.Helper()
+	m, err := metric.NewMetric("m", value)
+	require.NoError(t, err)
+	line, err := m.Serialize()
+	require.NoError(t, err)
+	return line
+}
+
+func TestValues(t *testing.T) {
+	t.Run("int64 values keep their precision", func(t *testing.T) {
+		above := number.NewInt64Number(maxSafeInteger + 1)
+		require.Equal(t, "m count,delta=9007199254740993", serializeValue(t, counterValue(above, number.Int64Kind, 1)))
+		require.Equal(t, "m gauge,9007199254740993", serializeValue(t, gaugeValue(above, number.Int64Kind, 1)))
+
+		max := number.NewInt64Number(math.MaxInt64)
+		require.Equal(t, "m gauge,9223372036854775807", serializeValue(t, gaugeValue(max, number.Int64Kind, 1)))
+		require.Equal(t, "m gauge,min=-9223372036854775808,max=9223372036854775807,sum=-1,count=2",
+			serializeValue(t, summaryValue(number.NewInt64Number(math.MinInt64), max, number.NewInt64Number(-1), 2, number.Int64Kind, 1)))
+	})
+
+	t.Run("float64 values are formatted as floats", func(t *testing.T) {
+		// 2^53 + 1 cannot be represented as a float64 and is rounded to 2^53.
+		above := number.NewFloat64Number(maxSafeInteger + 1)
+		require.Equal(t, "m count,delta=9.007199254740992e+15", serializeValue(t, counterValue(above, number.Float64Kind, 1)))
+		require.Equal(t, "m gauge,0.5", serializeValue(t, gaugeValue(number.NewFloat64Number(0.5), number.Float64Kind, 1)))
+	})
+
+	t.Run("converted int64 values are formatted as floats", func(t *testing.T) {
+		require.Equal(t, "m count,delta=1.5", serializeValue(t, counterValue(number.NewInt64Number(1500), number.Int64Kind, 1e-3)))
+	})
+}
+
+func Test_integerNumber(t *testing.T) {
+	n, ok := integerNumber(maxSafeInteger)
+	require.True(t, ok)
+	require.Equal(t, int64(maxSafeInteger), n.AsInt64())
+
+	_, ok = integerNumber(0.5)
+	require.False(t, ok)
+	_, ok = integerNumber(math.Inf(1))
+	require.False(t, ok)
+	_, ok = integerNumber(math.MaxInt64)
+	require.False(t, ok)
+}
+
+func TestExporter_Export_Int64Precision(t *testing.T) {
+	intHistDesc := metrictest.NewDescriptor("size", sdkapi.HistogramInstrumentKind, number.Int64Kind)
+	hists := histogram.New(2, &intHistDesc, histogram.WithExplicitBoundaries([]float64{10, 100}))
+	require.NoError(t, hists[0].Update(context.Background(), number.NewInt64Number(maxSafeInteger+1), &intHistDesc))
+	require.NoError(t, hists[0].Update(context.Background(), number.NewInt64Number(20), &intHistDesc))
+	require.NoError(t, hists[0].SynchronizedMove(&hists[1], &intHistDesc))
+
+	intCounterDesc := metrictest.NewDescriptor("bytes", sdkapi.CounterInstrumentKind, number.Int64Kind)
+	sums := sum.New(2)
+	require.NoError(t, sums[0].Update(context.Background(), number.NewInt64Number(maxSafeInteger+1), &intCounterDesc))
+	require.NoError(t, sums[0].SynchronizedMove(&sums[1], &intCounterDesc))
+
+	lines := exportLines(t, Options{}, resource.Empty(), map[instrumentation.Library][]export.Record{
+		{Name: "lib"}: {
+			export.NewRecord(&intCounterDesc, attribute.EmptySet(), sums[1].Aggregation(), intervalStart, intervalEnd),
+			counterRecord(t, "float.bytes", maxSafeInteger+1),
+			upDownRecord(t, -maxSafeInteger-3, intervalStart, intervalEnd),
+			export.NewRecord(&intHistDesc, attribute.EmptySet(), hists[1].Aggregation(), intervalStart, intervalEnd),
+		},
+	})
+
+	require.ElementsMatch(t, []string{
+		"bytes,dt.metrics.source=opentelemetry count,delta=9007199254740993 " + intervalEndMillis,
+		"float.bytes,dt.metrics.source=opentelemetry count,delta=9.007199254740992e+15 " + intervalEndMillis,
+		"queue,dt.metrics.source=opentelemetry,queue=jobs gauge,-9007199254740995 " + intervalEndMillis,
+		"size,dt.metrics.source=opentelemetry gauge,min=10,max=100,sum=9007199254741013,count=2 " + intervalEndMillis,
+	}, sortDimensionsOfLines(lines))
+}
@@ -16,10 +16,8 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"math/rand"
-	"os"
 	"time"
 
 	"go.opentelemetry.io/otel/metric/instrument"
@@ -32,14 +30,6 @@ import (
 	"github.com/dynatrace-oss/opentelemetry-metric-go/dynatrace"
 )
 
-func mustEnv(name string) string {
-	if val, exists := os.LookupEnv(name); exists {
-		return val
-	}
-
-	panic(fmt.Sprintf("Missing required environment variable: %s", name))
-}
-
 func main() {
 	logger, err := zap.NewDevelopment()
 
@@ -47,19 +37,11 @@ func main() {
 		log.Fatalf("Failed to start %v", err)
 	}
 
-	opts := dynatrace.Options{
+	// If DT_METRICS_INGEST_URL is not set, metrics will be exported to the local OneAgent endpoint.
+	// The API token is only required if an endpoint is provided.
+	exporter, err := dynatrace.NewExporterFromEnv(dynatrace.Options{
 		Logger: logger, // optional
-	}
-	// If no endpoint is provided, metrics will be exported to the local OneAgent endpoint
-	if endpoint, exists := os.LookupEnv("ENDPOINT"); exists {
-		opts.URL = endpoint
-		// API token is only required if an endpoint is provided
-		opts.APIToken = mustEnv("API_TOKEN")
-	} else {
-		logger.Sugar().Infow("Using local OneAgent API")
-	}
-
-	exporter, err := dynatrace.NewExporter(opts)
+	})
 	if err != nil {
 		panic(err)
 	}